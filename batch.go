@@ -0,0 +1,210 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Lanes is the number of independent sets of values processed at once by a
+// Batch.
+//
+const Lanes = 64
+
+// A BitwiseFn computes the outputs of a combinational part for 64 independent
+// sets of input values at once. in[i] holds the values of the input pin
+// PartSpec.Inputs[i], one bit per set of values (lane), and the function must
+// set out[i] to the values of the output pin PartSpec.Outputs[i] in the same
+// way.
+//
+// For example, the BitwiseFn of a Nand gate is:
+//
+//	func(in, out []uint64) { out[0] = ^(in[0] & in[1]) }
+//
+type BitwiseFn func(in, out []uint64)
+
+// A Batch evaluates the combinational logic of a circuit for 64 independent
+// sets of input values at once: each wire carries a uint64 value where each
+// bit is the value of the wire in one lane.
+//
+// Components with a Bitwise function are evaluated with a single call to that
+// function. Other components are updated once for every lane, through the
+// circuit's wires: the state of the circuit is undefined after a call to
+// Batch.Eval.
+//
+// A Batch does not run clock cycles: Eval only propagates the input values
+// through the combinational logic, like a Tick in a circuit without
+// sequential components. Circuits with sequential components must be
+// simulated with Circuit.Tick and Circuit.Tock, one set of values at a time.
+//
+type Batch struct {
+	idx   map[*Wire]int
+	v     []uint64
+	steps []batchStep
+}
+
+type batchStep struct {
+	c       *Component
+	in, out []int
+	iv, ov  []uint64
+}
+
+// NewBatch returns a new Batch for the combinational logic of circuit c. The
+// values of the given input wires are set with Batch.Set and the components
+// that drive them in c are ignored. Components with no outputs are ignored as
+// well.
+//
+// NewBatch returns an error if c contains sequential components or wiring
+// loops.
+//
+func NewBatch(c *Circuit, inputs ...*Wire) (*Batch, error) {
+	b := &Batch{
+		idx: map[*Wire]int{
+			c.wires[cstFalse]: cstFalse,
+			c.wires[cstTrue]:  cstTrue,
+			c.wires[cstClk]:   cstClk,
+		},
+		v: make([]uint64, cstCount),
+	}
+	b.v[cstTrue] = ^uint64(0)
+	isInput := make(map[int]bool, len(inputs))
+	for _, w := range inputs {
+		isInput[b.wire(w)] = true
+	}
+
+	isSource := func(cp *Component) bool {
+		for _, w := range cp.Outputs {
+			if w != nil && !isInput[b.wire(w)] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, cp := range c.comps {
+		if _, ok := cp.Updater.(PostUpdater); ok && !isSource(cp) {
+			return nil, errors.Errorf("%s: sequential component %s not supported", cp.Name, cp.Spec.Name)
+		}
+	}
+	comps, err := sortComponents(c.comps, isSource)
+	if err != nil {
+		return nil, err
+	}
+	b.steps = make([]batchStep, len(comps))
+	for i, cp := range comps {
+		st := &b.steps[i]
+		*st = batchStep{
+			c:   cp,
+			in:  make([]int, len(cp.Inputs)),
+			out: make([]int, len(cp.Outputs)),
+			iv:  make([]uint64, len(cp.Inputs)),
+			ov:  make([]uint64, len(cp.Outputs)),
+		}
+		for j, w := range cp.Inputs {
+			st.in[j] = b.wire(w)
+		}
+		for j, w := range cp.Outputs {
+			// outputs that are inputs of the batch are discarded
+			if st.out[j] = -1; w != nil && !isInput[b.wire(w)] {
+				st.out[j] = b.wire(w)
+			}
+		}
+	}
+	return b, nil
+}
+
+func (b *Batch) wire(w *Wire) int {
+	if w == nil {
+		return cstFalse
+	}
+	i, ok := b.idx[w]
+	if !ok {
+		i = len(b.v)
+		b.idx[w] = i
+		b.v = append(b.v, 0)
+	}
+	return i
+}
+
+// lookup returns the index of an existing wire in b. It panics if w is not
+// part of the batch.
+//
+func (b *Batch) lookup(w *Wire) int {
+	if w == nil {
+		return cstFalse
+	}
+	i, ok := b.idx[w]
+	if !ok {
+		panic(errors.Errorf("wire %p not in batch", w))
+	}
+	return i
+}
+
+// Set sets the values of wire w in all lanes: bit n of v is the value of the
+// wire in lane n. Setting the value of a constant wire has no effect.
+//
+// Set panics if w is not a wire of the batch's circuit.
+//
+func (b *Batch) Set(w *Wire, v uint64) {
+	if i := b.lookup(w); i >= cstCount {
+		b.v[i] = v
+	}
+}
+
+// Get returns the values of wire w in all lanes: bit n of the returned value
+// is the value of the wire in lane n.
+//
+// Get panics if w is not a wire of the batch's circuit.
+//
+func (b *Batch) Get(w *Wire) uint64 {
+	return b.v[b.lookup(w)]
+}
+
+// Eval updates the values of all wires in the batch given the values of its
+// inputs.
+//
+func (b *Batch) Eval() {
+	for i := range b.steps {
+		st := &b.steps[i]
+		for j, w := range st.in {
+			st.iv[j] = b.v[w]
+		}
+		if f := st.c.Spec.Bitwise; f != nil {
+			f(st.iv, st.ov)
+		} else {
+			st.eval()
+		}
+		for j, w := range st.out {
+			if w >= 0 {
+				b.v[w] = st.ov[j]
+			}
+		}
+	}
+}
+
+// eval updates a component that has no Bitwise function once per lane.
+//
+func (st *batchStep) eval() {
+	c := st.c
+	for j := range st.ov {
+		st.ov[j] = 0
+	}
+	for lane := uint(0); lane < Lanes; lane++ {
+		for j, w := range c.Inputs {
+			if w != nil {
+				w.clk, w.value = false, st.iv[j]&(1<<lane) != 0
+			}
+		}
+		for _, w := range c.Outputs {
+			if w != nil {
+				w.clk = true
+			}
+		}
+		c.Updater.Update(false)
+		for j, w := range c.Outputs {
+			if w != nil && w.value {
+				st.ov[j] |= 1 << lane
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package hwsim_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+func TestBatch(t *testing.T) {
+	and := &hwsim.PartSpec{
+		Name:    "AND",
+		Inputs:  []string{"a", "b"},
+		Outputs: []string{"out"},
+		Mount: func(s *hwsim.Socket) hwsim.Updater {
+			a, b, out := s.Wire("a"), s.Wire("b"), s.Wire("out")
+			return hwsim.UpdaterFn(func(clk bool) { out.Send(clk, a.Recv(clk) && b.Recv(clk)) })
+		},
+		Bitwise: func(in, out []uint64) { out[0] = in[0] & in[1] },
+	}
+	c, err := hwsim.NewCircuit(
+		hwsim.InputN(4, func() uint64 { return 0 })("out=a"),
+		hwsim.InputN(4, func() uint64 { return 0 })("out=b"),
+		// mix bitwise and regular components
+		tl.cla4("a=a, b=b, c0=false, out=s"),
+		and.NewPart("a=s[3], b=a[0], out=x"),
+		hwsim.OutputN(4, func(uint64) {})("in=s"),
+		hwsim.Output(func(bool) {})("in=x"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ins []*hwsim.Wire
+	for _, n := range []string{"a[0]", "a[1]", "a[2]", "a[3]", "b[0]", "b[1]", "b[2]", "b[3]"} {
+		ins = append(ins, c.Wire(n))
+	}
+	b, err := hwsim.NewBatch(c, ins...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lanes := make([]uint64, len(ins))
+	for i, w := range ins {
+		lanes[i] = rand.Uint64()
+		b.Set(w, lanes[i])
+	}
+	b.Eval()
+	x := b.Get(c.Wire("x"))
+	var s [4]uint64
+	for i := range s {
+		s[i] = b.Get(c.Wire("s[" + strconv.Itoa(i) + "]"))
+	}
+	for l := uint(0); l < hwsim.Lanes; l++ {
+		var va, vb, vs uint64
+		for i := uint(0); i < 4; i++ {
+			va |= lanes[i] >> l & 1 << i
+			vb |= lanes[4+i] >> l & 1 << i
+			vs |= s[i] >> l & 1 << i
+		}
+		if exp := (va + vb) & 15; vs != exp {
+			t.Fatalf("lane %d: %d + %d = %d, expected %d", l, va, vb, vs, exp)
+		}
+		if exp := vs >> 3 & va & 1; x>>l&1 != exp {
+			t.Fatalf("lane %d: x = %d, expected %d", l, x>>l&1, exp)
+		}
+	}
+
+	c, err = hwsim.NewCircuit(tl.dff("in=true, out=q"), hwsim.Output(func(bool) {})("in=q"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = hwsim.NewBatch(c); err == nil {
+		t.Fatal("expected error for sequential component")
+	}
+}
+
+func TestBatch_unknown_wire(t *testing.T) {
+	newCircuit := func() *hwsim.Circuit {
+		c, err := hwsim.NewCircuit(
+			hwsim.Input(func() bool { return false })("out=a"),
+			tl.nand("a=a, b=a, out=out"),
+			hwsim.Output(func(bool) {})("in=out"),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	c, other := newCircuit(), newCircuit()
+	b, err := hwsim.NewBatch(c, c.Wire("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := other.Wire("a")
+	for _, f := range []func(){
+		func() { b.Set(w, 1) },
+		func() { b.Get(w) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "not in batch") {
+					t.Errorf("expected panic on unknown wire, got %v", r)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -4,7 +4,6 @@
 package hwsim
 
 import (
-	"sort"
 	"strconv"
 	"strings"
 
@@ -14,7 +13,10 @@ import (
 type chip struct {
 	PartSpec             // PartSpec for this chip
 	parts    []*PartSpec // sub parts
+	names    []string    // sub part instance names
 	w        wiring
+	aliases  map[string][]string // wire name -> user given wire names
+	warnings []*WiringError      // see Lint
 }
 
 func (c *chip) mount(s *Socket) Updater {
@@ -23,7 +25,7 @@ func (c *chip) mount(s *Socket) Updater {
 	}
 	for i, p := range c.parts {
 		// make a sub-socket
-		sub := newSocket(s.c)
+		sub := newSocket(s.c, s.path+c.names[i]+".")
 		// k is the exported pin name (always an input or output name)
 		// subK is the pin name in the part's namespace
 		for k, subK := range p.Pinout {
@@ -31,19 +33,25 @@ func (c *chip) mount(s *Socket) Updater {
 				continue
 			}
 			if n := c.w.wireName(pin{i, k}); n != "" {
-				sub.m[subK] = s.wireOrNew(n)
+				w := s.wireOrNew(n)
+				for _, a := range c.aliases[n] {
+					s.c.nameWire(s.path+a, w)
+				}
+				sub.m[subK] = w
 				// log.Printf("%s:%s pin %s (%s) on wire %s = %p", c.Name, p.Name, k, subK, n, sub.m[subK])
 			} else if sub.m[subK] == nil {
 				// Chip() makes sure that unknown pins can only be inputs.
 				sub.m[subK] = s.Wire(False)
 				// log.Printf("%s:%s pin %s (%s) on wire ??? = FALSE", c.Name, p.Name, k, subK)
 			}
+			s.c.nameWire(sub.path+k, sub.m[subK])
 		}
 		up := p.Mount(sub)
 		impl.ups[i] = up
 		if _, ok := up.(Wrapper); ok {
 			continue
 		}
+		s.c.comps = append(s.c.comps, newComponent(sub, p, up))
 		for _, k := range p.Outputs {
 			subK := p.Pinout[k]
 			if subK == "" {
@@ -57,6 +65,25 @@ func (c *chip) mount(s *Socket) Updater {
 	return impl
 }
 
+func newComponent(s *Socket, p *PartSpec, up Updater) *Component {
+	wires := func(pins []string) []*Wire {
+		ws := make([]*Wire, len(pins))
+		for i, k := range pins {
+			if subK := p.Pinout[k]; subK != "" {
+				ws[i] = s.m[subK]
+			}
+		}
+		return ws
+	}
+	return &Component{
+		Name:    strings.TrimSuffix(s.path, "."),
+		Spec:    p,
+		Inputs:  wires(p.Inputs),
+		Outputs: wires(p.Outputs),
+		Updater: up,
+	}
+}
+
 type chipImpl struct {
 	ups []Updater
 }
@@ -88,7 +115,6 @@ func (c *chipImpl) Unwrap() []Updater {
 //		hwlib.Nand("a=w0, b=w1, out=out"),
 //	)
 //
-//
 // The created chip can be composed with other parts to create other chips
 // simply by calling the returned NewPartFn with a connection configuration:
 //
@@ -114,71 +140,118 @@ func Chip(name string, inputs string, outputs string, parts ...Part) (NewPartFn,
 
 	wr := newWiring(ins, outs)
 	spcs := make([]*PartSpec, len(parts))
-
-	isOut := func(p *PartSpec, name string) bool {
-		n := sort.SearchStrings(p.Outputs, name)
-		return n < len(p.Outputs) && p.Outputs[n] == name
+	// loc maps part pins to the index of their connection in Part.Conns
+	loc := make(map[pin]int)
+	wErr := func(pnum, i int, cp bool, err error) error {
+		p := &parts[pnum]
+		return &WiringError{Chip: name, Part: pnum, PartName: p.Name, Conn: p.conn, Col: p.col(i, cp), Err: err}
 	}
 
+	widths := busWidths(ins, outs, parts)
+
 	for pnum := range parts {
 		p := &parts[pnum]
 		spcs[pnum] = p.PartSpec
 		conns := make([]Connection, 0, len(p.Conns))
-		sort.Strings(p.Outputs)
+		from := make([]int, 0, len(p.Conns)) // index in p.Conns of each entry in conns
+		isOut := make(map[string]bool, len(p.Outputs))
+		for _, k := range p.Outputs {
+			isOut[k] = true
+		}
 
 		// expand buses
 		for i := range p.Conns {
 			c := &p.Conns[i]
 			k := c.PP
 			if _, ok := p.Pinout[k]; ok {
+				if c.Const {
+					cs, err := spreadConstant(c.Value, 1)
+					if err != nil {
+						return nil, wErr(pnum, i, true, errors.Wrap(err, "pin "+k))
+					}
+					c = &Connection{PP: k, CP: cs}
+				}
 				conns = append(conns, *c)
+				from = append(from, i)
 				continue
 			}
 			// bus?
 			if _, ok := p.Pinout[pinName(k, 0)]; !ok {
-				return nil, errors.New("invalid pin name " + k + " for part " + p.Name)
+				return nil, wErr(pnum, i, false, errors.New("invalid pin name "+k))
 			}
-			for _, v := range c.CP {
-				if strings.IndexRune(v, '[') >= 0 {
-					return nil, errors.New("cannot map bus " + k + " to single pin " + v + " for part " + p.Name)
+			bits := 1
+			for {
+				if _, ok := p.Pinout[pinName(k, bits)]; !ok {
+					break
 				}
+				bits++
 			}
-			for i := 0; ; i++ {
-				pp := pinName(k, i)
-				if _, ok := p.Pinout[pp]; !ok {
-					break
+			if c.Const {
+				cs, err := spreadConstant(c.Value, bits)
+				if err != nil {
+					return nil, wErr(pnum, i, true, errors.Wrap(err, "bus "+k))
 				}
+				for j := range cs {
+					conns = append(conns, Connection{PP: pinName(k, j), CP: cs[j : j+1]})
+					from = append(from, i)
+				}
+				continue
+			}
+			if c.Bits {
+				// bus slice or concatenation: one chip pin per bus bit
+				var cp []string
+				for _, v := range c.CP {
+					// whole bus in a concatenation
+					if n := widths[v]; n > 0 {
+						for j := 0; j < n; j++ {
+							cp = append(cp, pinName(v, j))
+						}
+						continue
+					}
+					cp = append(cp, v)
+				}
+				if len(cp) != bits {
+					return nil, wErr(pnum, i, true, errors.Errorf("bus %s has %d bits, connected to %d pins", k, bits, len(cp)))
+				}
+				for j, v := range cp {
+					conns = append(conns, Connection{PP: pinName(k, j), CP: []string{v}})
+					from = append(from, i)
+				}
+				continue
+			}
+			for j := 0; j < bits; j++ {
 				cp := make([]string, len(c.CP))
-				for j, v := range c.CP {
-					cp[j] = pinName(v, i)
+				for n, v := range c.CP {
+					cp[n] = pinName(v, j)
 				}
-				conns = append(conns, Connection{PP: pp, CP: cp})
+				conns = append(conns, Connection{PP: pinName(k, j), CP: cp})
+				from = append(from, i)
 			}
 		}
 
 		// Add the part's pins tho the wiring
-		for i := range conns {
-			c := &conns[i]
+		for n := range conns {
+			c := &conns[n]
 			k := c.PP
+			i := from[n]
 
 			// check that the pin matches one of the part's input or output pins
 			if _, ok := p.Pinout[k]; !ok {
-				return nil, errors.New("invalid pin name " + k + " for part " + p.Name)
+				return nil, wErr(pnum, i, false, errors.New("invalid pin name "+k))
 			}
-			if isOut(p.PartSpec, k) {
+			loc[pin{pnum, k}] = i
+			if isOut[k] {
 				for _, v := range c.CP {
-					i, o := pin{pnum, k}, pin{-1, v}
-					if err := wr.connect(i, typeOutput, tmpName(pnum, k), o, typeUnknown); err != nil {
-						return nil, errors.Wrap(err, getPinName(spcs, i)+":"+getPinName(spcs, o))
+					if err := wr.connect(pin{pnum, k}, typeOutput, tmpName(pnum, k), pin{-1, v}, typeUnknown); err != nil {
+						return nil, wErr(pnum, i, true, errors.Wrap(err, k+"="+v))
 					}
 				}
 			} else {
 				if len(c.CP) > 1 {
-					return nil, errors.New(p.Name + " input pin " + k + "connected to more than one output")
+					return nil, wErr(pnum, i, true, errors.New("input pin "+k+" connected to more than one output"))
 				}
-				i, o := pin{-1, c.CP[0]}, pin{pnum, k}
-				if err := wr.connect(i, typeUnknown, c.CP[0], o, typeInput); err != nil {
-					return nil, errors.Wrap(err, getPinName(spcs, i)+":"+getPinName(spcs, o))
+				if err := wr.connect(pin{-1, c.CP[0]}, typeUnknown, c.CP[0], pin{pnum, k}, typeInput); err != nil {
+					return nil, wErr(pnum, i, true, errors.Wrap(err, k+"="+c.CP[0]))
 				}
 			}
 		}
@@ -208,8 +281,31 @@ func Chip(name string, inputs string, outputs string, parts ...Part) (NewPartFn,
 		}
 	}
 
-	if err := wr.prune(); err != nil {
-		return nil, err
+	// keep track of the user given names of chip wires, most of which are
+	// removed by prune.
+	var named []*node
+	for p, n := range wr {
+		if p.p < 0 && !isCstPin(p.name) {
+			named = append(named, n)
+		}
+	}
+
+	warnings := lintIO(name, ins, outs, wr)
+
+	if p, err := wr.prune(); err != nil {
+		if i, ok := loc[p]; ok {
+			return nil, wErr(p.p, i, true, err)
+		}
+		return nil, &WiringError{Chip: name, Part: -1, Err: err}
+	}
+
+	warnings = append(warnings, lintParts(name, parts, wr)...)
+
+	aliases := make(map[string][]string)
+	for _, n := range named {
+		if wn := n.root().name; wn != n.pin.name {
+			aliases[wn] = append(aliases[wn], n.pin.name)
+		}
 	}
 
 	pinout := make(map[string]string)
@@ -229,17 +325,86 @@ func Chip(name string, inputs string, outputs string, parts ...Part) (NewPartFn,
 			Pinout:  pinout,
 		},
 		spcs,
+		instanceNames(spcs),
 		wr,
+		aliases,
+		warnings,
 	}
 	c.PartSpec.Mount = c.mount
+	c.PartSpec.chip = c
 	return c.PartSpec.NewPart, nil
 }
 
-func getPinName(sp []*PartSpec, p pin) string {
-	if p.p < 0 {
-		return p.name
+// busWidths returns the width of the chip buses that can be referred to by
+// their name alone: the buses of the chip's I/O specification and the buses
+// connected to part pins, as a whole or by index. It is used to expand whole
+// buses in concatenations.
+//
+func busWidths(ins, outs []string, parts []Part) map[string]int {
+	widths := make(map[string]int)
+	set := func(p string, bits int) {
+		if bits > widths[p] {
+			widths[p] = bits
+		}
+	}
+	index := func(p string) {
+		if i := strings.IndexByte(p, '['); i >= 0 {
+			if n, err := strconv.Atoi(p[i+1 : len(p)-1]); err == nil {
+				set(p[:i], n+1)
+			}
+		}
+	}
+	for _, ps := range [][]string{ins, outs} {
+		for _, p := range ps {
+			index(p)
+		}
 	}
-	return sp[p.p].Name + "." + p.name
+	for _, p := range parts {
+		for _, c := range p.Conns {
+			if c.Bits {
+				for _, v := range c.CP {
+					index(v)
+				}
+				continue
+			}
+			if _, ok := p.Pinout[c.PP]; ok || c.Const {
+				continue
+			}
+			bits := 0
+			for {
+				if _, ok := p.Pinout[pinName(c.PP, bits)]; !ok {
+					break
+				}
+				bits++
+			}
+			for _, v := range c.CP {
+				set(v, bits)
+			}
+		}
+	}
+	return widths
+}
+
+// instanceNames returns the instance names of the given parts: parts with a
+// unique name in the list are named after their PartSpec, others are suffixed
+// with '#' and their rank among the parts with the same name.
+//
+func instanceNames(sp []*PartSpec) []string {
+	cnt := make(map[string]int)
+	for _, p := range sp {
+		cnt[p.Name]++
+	}
+	rank := make(map[string]int)
+	names := make([]string, len(sp))
+	for i, p := range sp {
+		if cnt[p.Name] == 1 {
+			names[i] = p.Name
+			continue
+		}
+		names[i] = p.Name + "#" + strconv.Itoa(rank[p.Name])
+		rank[p.Name]++
+	}
+	return names
 }
 
 func tmpName(pnum int, k string) string {
@@ -407,7 +572,9 @@ func (wr wiring) wireName(p pin) string {
 // It removes unconnected chip pins and ephemeral pins by establishing direct
 // connections between parts and I/O pins and assigns names to individual wires.
 //
-func (wr wiring) prune() error {
+// On error, it returns the part pin connected to the faulty wire, if any.
+//
+func (wr wiring) prune() (pin, error) {
 	for k, n := range wr {
 		// remove input pins with no outs
 		if n.isChipInput() && len(n.outs) == 0 {
@@ -423,10 +590,21 @@ func (wr wiring) prune() error {
 		// Error on ephemeral pins with no source or dest.
 		// error on output pins with dests within the chip but no src.
 		if (n.typ == typeUnknown || n.isChipOutput()) && n.src == nil {
-			return errors.New("pin " + n.pin.name + " not connected to any output")
+			p := n.pin
+			for _, o := range n.outs {
+				if o.pin.p >= 0 {
+					p = o.pin
+					break
+				}
+			}
+			return p, errors.New("pin " + n.pin.name + " not connected to any output")
 		}
 		if n.typ == typeUnknown && len(n.outs) == 0 {
-			return errors.New("pin " + n.pin.name + " not connected to any input")
+			p := n.pin
+			if n.src != nil {
+				p = n.src.pin
+			}
+			return p, errors.New("pin " + n.pin.name + " not connected to any input")
 		}
 
 		// remove temporary pins.
@@ -457,5 +635,5 @@ func (wr wiring) prune() error {
 			i++
 		}
 	}
-	return nil
+	return pin{}, nil
 }
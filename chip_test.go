@@ -1,6 +1,7 @@
 package hwsim_test
 
 import (
+	"reflect"
 	"testing"
 
 	hw "github.com/db47h/hwsim"
@@ -19,56 +20,99 @@ func TestChip_errors(t *testing.T) {
 		in    string
 		out   string
 		parts []hw.Part
+		part  int
+		col   int
 		err   string
 	}{
 		{"true_out", "a, b", "out", []hw.Part{
 			tl.nand("a=a, b=b, out=true"),
 			tl.nand("a=a, b=b, out=out"),
-		}, "NAND.out:true: output pin connected to constant true input"},
+		}, 0, 15, "out=true: output pin connected to constant true input"},
 		{"false_out", "a, b", "out", []hw.Part{
 			tl.nand("a=a, b=b, out=false"),
 			tl.nand("a=a, b=b, out=out"),
-		}, "NAND.out:false: output pin connected to constant false input"},
+		}, 0, 15, "out=false: output pin connected to constant false input"},
 		{"multi_out", "a, b", "out", []hw.Part{
 			tl.nand("a=a, b=b, out=a"),
 			tl.nand("a=a, b=b, out=out"),
-		}, "NAND.out:a: chip input pin used as output"},
+		}, 0, 15, "out=a: chip input pin used as output"},
 		{"multi_out2", "a, b", "out", []hw.Part{
 			tl.nand("a=a, b=b, out=x"),
 			tl.nand("a=a, b=b, out=x"),
 			tl.not("in=x, out=out"),
-		}, "NAND.out:x: output pin already used as output"},
+		}, 1, 15, "out=x: output pin already used as output"},
 		{"no_output", "a, b", "out", []hw.Part{
 			tl.nand("a=a, b=wx, out=out"),
-		}, "pin wx not connected to any output"},
+		}, 0, 8, "pin wx not connected to any output"},
 		{"no_output", "", "out", []hw.Part{
 			tl.not("in=out"),
-		}, "pin out not connected to any output"},
+		}, 0, 4, "pin out not connected to any output"},
 		{"no_input", "a, b", "out", []hw.Part{
 			tl.nand("a=a, b=b, out=foo"),
 			tl.nand("a=a, b=b, out=out"),
-		}, "pin foo not connected to any input"},
-		{"unconnected_in", "a, b", "out", []hw.Part{}, ""},
+		}, 0, 15, "pin foo not connected to any input"},
+		{"unconnected_in", "a, b", "out", []hw.Part{}, 0, 0, ""},
 		{"unknown_pin", "a, b", "out", []hw.Part{
 			tl.nand("a=a, typo=b, out=out"),
-		}, "invalid pin name typo for part NAND"},
+		}, 0, 6, "invalid pin name typo"},
 		{"unknown_pin", "a, b", "out", []hw.Part{
+			tl.nand("a=a, b=b, out=out"),
 			unkChip("a=a, typo=b, out=out"),
-		}, "invalid pin name typo for part TESTCHIP"},
+		}, 1, 6, "invalid pin name typo"},
 		{"unknown_pin", "a, b", "out", []hw.Part{
 			unkChip("a=a, b=b, out=out"),
-		}, ""},
+		}, 0, 0, ""},
+		{"wide_constant", "a, b", "out", []hw.Part{
+			tl.nand("a=a, b=2, out=out"),
+		}, 0, 8, "pin b: constant 2 wider than 1 bits"},
+		{"wide_bus_constant", "a[4]", "out[4]", []hw.Part{
+			tl.cla4("a=a, b=0x1F, out=out"),
+		}, 0, 8, "bus b: constant 31 wider than 4 bits"},
+		{"multi_in", "a, b", "out", []hw.Part{
+			tl.nand("a=a, b={a, b}, out=out"),
+		}, 0, 8, "input pin b connected to more than one output"},
 	}
 	for _, d := range data {
 		t.Run(d.name, func(t *testing.T) {
 			_, err := hw.Chip(d.name, d.in, d.out, d.parts...)
-			if err == nil && d.err != "" || err != nil && err.Error() != d.err {
-				t.Errorf("Got error %q, expected %q", err, d.err)
+			if err == nil {
+				if d.err != "" {
+					t.Errorf("Got no error, expected %q", d.err)
+				}
 				return
 			}
+			we, ok := err.(*hw.WiringError)
+			if !ok {
+				t.Fatalf("Got error %q of type %T, expected a *WiringError", err, err)
+			}
+			if we.Err.Error() != d.err || we.Chip != d.name || we.Part != d.part || we.Col != d.col {
+				t.Errorf("Got error %q in chip %s, part %d, col %d, expected %q in chip %s, part %d, col %d",
+					we.Err, we.Chip, we.Part, we.Col, d.err, d.name, d.part, d.col)
+			}
+			if p := d.parts[d.part]; we.PartName != p.Name {
+				t.Errorf("Got part name %s, expected %s", we.PartName, p.Name)
+			}
 		})
 	}
 
+	const exp = `chip X: part 0 NAND("a=a, b=wx, out=out") col 8: pin wx not connected to any output`
+	if _, err = hw.Chip("X", "a", "out", tl.nand("a=a, b=wx, out=out")); err == nil || err.Error() != exp {
+		t.Errorf("Got error %q, expected %q", err, exp)
+	}
+}
+
+func TestNewPart_errors(t *testing.T) {
+	defer func() {
+		r := recover()
+		we, ok := r.(*hw.WiringError)
+		if !ok {
+			t.Fatalf("Got %v, expected a *WiringError", r)
+		}
+		if we.PartName != "NAND" || we.Part != -1 || we.Conn != "a=a, b=(, out=out" || we.Col != 8 {
+			t.Errorf("Got %#v", we)
+		}
+	}()
+	tl.nand("a=a, b=(, out=out")
 }
 
 func TestChip_omitted_pins(t *testing.T) {
@@ -114,6 +158,42 @@ func TestChip_omitted_pins(t *testing.T) {
 	}
 }
 
+func TestChip_outputs_order(t *testing.T) {
+	spec := &hw.PartSpec{
+		Name:    "swap",
+		Inputs:  hw.IO("a, b"),
+		Outputs: hw.IO("z, y"),
+		Mount: func(s *hw.Socket) hw.Updater {
+			a, b, z, y := s.Wire("a"), s.Wire("b"), s.Wire("z"), s.Wire("y")
+			return hw.UpdaterFn(func(clk bool) {
+				z.Send(clk, a.Recv(clk))
+				y.Send(clk, b.Recv(clk))
+			})
+		}}
+	swap, err := hw.Chip("wrapper", "a, b", "z, y", spec.NewPart("a=a, b=b, z=z, y=y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"z", "y"}; !reflect.DeepEqual(spec.Outputs, exp) {
+		t.Fatalf("expected outputs %v, got %v", exp, spec.Outputs)
+	}
+	var z, y bool
+	c, err := hw.NewCircuit(
+		hw.Input(func() bool { return true })("out=a"),
+		hw.Input(func() bool { return false })("out=b"),
+		swap("a=a, b=b, z=z, y=y"),
+		hw.Output(func(v bool) { z = v })("in=z"),
+		hw.Output(func(v bool) { y = v })("in=y"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.TickTock()
+	if !z || y {
+		t.Fatalf("expected z = true, y = false, got %v, %v", z, y)
+	}
+}
+
 func TestChip_fanout_to_outputs(t *testing.T) {
 	gate, err := hw.Chip("FANOUT", "in", "a, b, bus[2], c",
 		tl.or("a=in, b=in, out=a, out=b, out=bus[0..1]"),
@@ -142,3 +222,144 @@ func TestChip_fanout_to_outputs(t *testing.T) {
 		t.Fatalf("out = %d != 255", out)
 	}
 }
+
+func TestChip_constants(t *testing.T) {
+	conns, err := hw.ParseConnections("a[0..3]=0b1010, sel=1, b=0x3F, c[1]=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []hw.Connection{
+		{PP: "a[0]", CP: []string{hw.False}},
+		{PP: "a[1]", CP: []string{hw.True}},
+		{PP: "a[2]", CP: []string{hw.False}},
+		{PP: "a[3]", CP: []string{hw.True}},
+		{PP: "sel", Const: true, Value: 1},
+		{PP: "b", Const: true, Value: 63},
+		{PP: "c[1]", CP: []string{hw.False}},
+	}
+	if !reflect.DeepEqual(conns, exp) {
+		t.Fatalf("expected %v, got %v", exp, conns)
+	}
+	for _, c := range []string{
+		"a[0..3]=0x10", "a=0x", "a=0b2", "a=0x1FFFFFFFFFFFFFFFF",
+		"a[0..3]=16", "a[0..3]=18446744073709551616", "sel=18446744073709551617",
+		"a=99999999999999999999", "a[99999999999]=b", "a[0..99999999999999999999]=b",
+	} {
+		if _, err = hw.ParseConnections(c); err == nil {
+			t.Errorf("no error parsing %q", c)
+		}
+	}
+	if _, err = hw.ParseConnections("a=18446744073709551615"); err != nil {
+		t.Errorf("error parsing the largest decimal literal: %v", err)
+	}
+
+	add3, err := hw.Chip("ADD3", "a[4]", "out[4]",
+		tl.cla4("a=a, b=3, c0=0, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a, out uint64
+	c, err := hw.NewCircuit(
+		hw.InputN(4, func() uint64 { return a })("out=a"),
+		add3("a=a, out=out"),
+		hw.OutputN(4, func(v uint64) { out = v })("in=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for a = 0; a < 16; a++ {
+		c.TickTock()
+		if out != (a+3)&15 {
+			t.Fatalf("%d + 3 = %d", a, out)
+		}
+	}
+}
+
+func TestChip_slices(t *testing.T) {
+	conns, err := hw.ParseConnections("in={hi[0..1], lo}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []hw.Connection{{PP: "in", CP: []string{"lo", "hi[0]", "hi[1]"}, Bits: true}}
+	if !reflect.DeepEqual(conns, exp) {
+		t.Fatalf("expected %v, got %v", exp, conns)
+	}
+	for _, c := range []string{"in={a, b", "in={}", "in={a[0..1] b}"} {
+		if _, err = hw.ParseConnections(c); err == nil {
+			t.Errorf("no error parsing %q", c)
+		}
+	}
+
+	var x, hi, swap, sum, ab, one uint64
+	var a, b bool
+	c, err := hw.NewCircuit(
+		hw.InputN(8, func() uint64 { return x })("out=x"),
+		hw.OutputN(4, func(v uint64) { hi = v })("in=x[4..7]"),
+		hw.OutputN(8, func(v uint64) { swap = v })("in={x[0..3], x[4..7]}"),
+		tl.cla4("a=x[0..3], b=x[4..7], out={s[0..1], s[2..3]}"),
+		hw.OutputN(4, func(v uint64) { sum = v })("in=s[0..3]"),
+		hw.Input(func() bool { return a })("out=a"),
+		hw.Input(func() bool { return b })("out=b"),
+		hw.OutputN(2, func(v uint64) { ab = v })("in={a, b}"),
+		hw.OutputN(1, func(v uint64) { one = v })("in={a}"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, a = 0xA7, true
+	c.TickTock()
+	if hi != 0xA || swap != 0x7A || sum != 0x4 {
+		t.Fatalf("x = %#x: hi = %#x, swap = %#x, sum = %#x", x, hi, swap, sum)
+	}
+	if ab != 2 || one != 1 {
+		t.Fatalf("a = %v, b = %v: {a, b} = %#x, {a} = %#x", a, b, ab, one)
+	}
+
+	// whole buses in a concatenation
+	var h, l, cat uint64
+	c, err = hw.NewCircuit(
+		hw.InputN(2, func() uint64 { return h })("out=hi"),
+		hw.InputN(2, func() uint64 { return l })("out=lo"),
+		hw.OutputN(4, func(v uint64) { cat = v })("in={hi, lo}"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, l = 2, 1
+	c.TickTock()
+	if cat != 0x9 {
+		t.Fatalf("hi = %#x, lo = %#x: {hi, lo} = %#x", h, l, cat)
+	}
+	cat4, err := hw.Chip("cat4", "hi[2], lo[2]", "out[4]",
+		hw.OutputN(4, func(v uint64) { cat = v })("in={hi, lo}"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = hw.NewCircuit(
+		hw.InputN(4, func() uint64 { return x })("out=x"),
+		cat4("hi=x[2..3], lo=x[0..1]"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x = 0x6
+	c.TickTock()
+	if cat != x {
+		t.Fatalf("x = %#x: {hi, lo} = %#x", x, cat)
+	}
+
+	_, err = hw.Chip("bad_slice", "x[4]", "out[4]",
+		tl.cla4("a=x[0..2], b=x, out=out"),
+	)
+	if exp := "bus a has 4 bits, connected to 3 pins"; err == nil || err.(*hw.WiringError).Err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+	_, err = hw.Chip("bad_concat", "x", "out[4]",
+		tl.cla4("a={x}, b=false, out=out"),
+	)
+	if exp := "bus a has 4 bits, connected to 1 pins"; err == nil || err.(*hw.WiringError).Err.Error() != exp {
+		t.Fatalf("expected error %q, got %v", exp, err)
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Clock returns a clock source whose period is div cycles of the main
+// circuit clock. Connecting its output to a named wire creates a named clock
+// that can drive clocked components like hwlib.DFFClk:
+//
+//	hwsim.Clock(4)("out=uartClk")
+//
+// Like the clk constant input, the output is false during the first half of
+// its period and true during the second half, so its falling edges always
+// coincide with a call to Circuit.Tick. Since all clocks are derived from the
+// main clock, edges of different clocks that happen during the same half
+// cycle are processed at the same time: clocked components all sample their
+// inputs after combinational logic has been updated.
+//
+// Clock panics if div < 1.
+//
+func Clock(div int) NewPartFn {
+	if div < 1 {
+		panic(errors.Errorf("invalid clock divider %d", div))
+	}
+	return (&PartSpec{
+		Name:    "Clock" + strconv.Itoa(div),
+		Inputs:  nil,
+		Outputs: []string{"out"},
+		Mount: func(s *Socket) Updater {
+			out := s.Wire("out")
+			c := s.c
+			return UpdaterFn(func(clk bool) {
+				out.Send(clk, c.clockAt(div))
+			})
+		}}).NewPart
+}
+
+// clockAt returns the value of a clock of period div during the current half
+// cycle.
+//
+func (c *Circuit) clockAt(div int) bool {
+	if c.half < 0 {
+		// Eval before the first Tick: as if Tock had just been called.
+		return true
+	}
+	return (c.half/int64(div))%2 == 1
+}
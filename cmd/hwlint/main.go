@@ -0,0 +1,155 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+// Command hwlint runs style checks on chips loaded from HDL or JSON netlist
+// files.
+//
+// Usage:
+//
+//	hwlint [flags] file...
+//
+// All the chips declared in the given files are checked, or only the one
+// named with -chip. Parts not found in the files or in the built-in library
+// are loaded from files named after them (e.g. Xor.hdl) in the same directory
+// as the file that uses them.
+//
+// The checks are selected with flags:
+//
+//	-only parts	comma separated list of the only parts allowed, like
+//			"Nand" or "Nand,DFF" for gate-level only assignments.
+//	-noclk		forbid direct use of clk outside sequential parts.
+//	-fanout n	maximum fan-out of any wire.
+//	-depth n	maximum hierarchy depth.
+//	-bus regexp	regular expression that bus names must match.
+//	-wiring		report unused inputs, unconnected outputs and similar
+//			wiring issues (enabled by default).
+//
+// Problems are printed one per line. hwlint exits with status 1 if any
+// problem is found.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/db47h/hwsim/hwlint"
+	"github.com/db47h/hwsim/internal/netlist"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	n, err := run(os.Args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "hwlint:", err)
+		os.Exit(2)
+	}
+	if n > 0 {
+		os.Exit(1)
+	}
+}
+
+// run runs hwlint with the given command line arguments and returns the
+// number of problems found.
+//
+func run(args []string, stdout io.Writer) (int, error) {
+	fs := flag.NewFlagSet("hwlint", flag.ContinueOnError)
+	var (
+		chip   = fs.String("chip", "", "check only the chip with the given `name`")
+		only   = fs.String("only", "", "comma separated list of the only `parts` allowed")
+		noClk  = fs.Bool("noclk", false, "forbid direct use of clk outside sequential parts")
+		fanOut = fs.Int("fanout", 0, "maximum fan-out of any wire (0: no limit)")
+		depth  = fs.Int("depth", 0, "maximum hierarchy depth (0: no limit)")
+		bus    = fs.String("bus", "", "`regexp` that bus names must match")
+		wiring = fs.Bool("wiring", true, "report wiring issues")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: hwlint [flags] file.hdl|file.json...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 0, errors.New("no chip file specified")
+	}
+
+	n := 0
+	found := false
+	seen := make(map[string]bool) // problems in sub-chips are reported once
+	for _, file := range fs.Args() {
+		lib := netlist.NewLibrary(filepath.Dir(file))
+		decls, err := lib.LoadFile(file)
+		if err != nil {
+			return n, err
+		}
+
+		var rules []hwlint.Rule
+		if *only != "" {
+			// resolve library names like Nand to part names like NAND
+			var names []string
+			for _, s := range strings.Split(*only, ",") {
+				s = strings.TrimSpace(s)
+				p, err := lib.Lookup(s)
+				if err != nil {
+					return n, errors.Wrap(err, "-only")
+				}
+				names = append(names, p("").Name)
+			}
+			rules = append(rules, hwlint.OnlyParts(names...))
+		}
+		if *noClk {
+			rules = append(rules, hwlint.NoClk())
+		}
+		if *fanOut > 0 {
+			rules = append(rules, hwlint.MaxFanOut(*fanOut))
+		}
+		if *depth > 0 {
+			rules = append(rules, hwlint.MaxDepth(*depth))
+		}
+		if *bus != "" {
+			re, err := regexp.Compile(*bus)
+			if err != nil {
+				return n, errors.Wrap(err, "-bus")
+			}
+			rules = append(rules, hwlint.BusNames(re))
+		}
+		if *wiring {
+			rules = append(rules, hwlint.Wiring())
+		}
+
+		for _, d := range decls {
+			if *chip != "" && d.Name != *chip {
+				continue
+			}
+			found = true
+			p, err := lib.Lookup(d.Name)
+			if err != nil {
+				return n, err
+			}
+			ps, err := hwlint.Check(p, rules...)
+			if err != nil {
+				return n, errors.Wrapf(err, "%s: chip %s", file, d.Name)
+			}
+			for i := range ps {
+				s := ps[i].String()
+				if seen[s] {
+					continue
+				}
+				seen[s] = true
+				fmt.Fprintf(stdout, "%s: %s: %s\n", file, d.Name, s)
+				n++
+			}
+		}
+	}
+	if !found && *chip != "" {
+		return n, errors.Errorf("no chip named %s", *chip)
+	}
+	return n, nil
+}
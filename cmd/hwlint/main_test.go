@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const hdl = `CHIP MyXor {
+	IN a, b;
+	OUT out;
+	PARTS:
+	Nand(a=a, b=b, out=nab);
+	Nand(a=a, b=nab, out=o1);
+	Nand(a=nab, b=b, out=o2);
+	Nand(a=o1, b=o2, out=out);
+}
+
+CHIP Sel {
+	IN a, b, sel, unused;
+	OUT out;
+	PARTS:
+	MyXor(a=a, b=b, out=x);
+	Mux(a=a, b=x, sel=sel, out=out);
+}
+`
+
+func Test_run(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "Sel.hdl")
+	if err := ioutil.WriteFile(fn, []byte(hdl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	n, err := run([]string{"-only", "Nand", "-fanout", "2", fn}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := fn + ": Sel: only-parts: Sel.MUX: part MUX not allowed\n" +
+		fn + ": Sel: max-fanout: a: fan-out of 3 exceeds 2\n" +
+		fn + ": Sel: wiring: Sel: input unused not used\n"
+	if n != 3 || out.String() != exp {
+		t.Errorf("got %d problems:\n%s\nexpected:\n%s", n, out.String(), exp)
+	}
+
+	out.Reset()
+	if n, err = run([]string{"-chip", "MyXor", "-only", "Nand", fn}, &out); err != nil || n != 0 {
+		t.Errorf("got %d problems, error %v:\n%s", n, err, out.String())
+	}
+	if _, err = run([]string{"-chip", "Foo", fn}, &out); err == nil {
+		t.Error("no error for unknown chip")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/db47h/hwsim"
+	"github.com/pkg/errors"
+)
+
+// A port is a chip input or output pin or bus.
+//
+type port struct {
+	name string
+	bits int
+	v    uint64
+}
+
+// ports groups pin names into ports. Pins of a bus are grouped together only if
+// the bus indices start at 0 and are contiguous.
+//
+func ports(pins []string) []*port {
+	var out []*port
+	buses := make(map[string]*port)
+	idx := make(map[string][]int)
+	for _, n := range pins {
+		b := strings.IndexRune(n, '[')
+		if b < 0 {
+			out = append(out, &port{name: n, bits: 1})
+			continue
+		}
+		bn := n[:b]
+		i, err := strconv.Atoi(n[b+1 : len(n)-1])
+		if err != nil {
+			panic(err)
+		}
+		if buses[bn] == nil {
+			buses[bn] = &port{name: bn}
+			out = append(out, buses[bn])
+		}
+		idx[bn] = append(idx[bn], i)
+	}
+	// expand non-contiguous buses to individual pins
+	res := out[:0:0]
+	for _, p := range out {
+		l, ok := idx[p.name]
+		if !ok {
+			res = append(res, p)
+			continue
+		}
+		seen := make([]bool, len(l))
+		contiguous := true
+		for _, i := range l {
+			if i >= len(l) || seen[i] {
+				contiguous = false
+				break
+			}
+			seen[i] = true
+		}
+		if contiguous {
+			p.bits = len(l)
+			res = append(res, p)
+			continue
+		}
+		for _, i := range l {
+			res = append(res, &port{name: p.name + "[" + strconv.Itoa(i) + "]", bits: 1})
+		}
+	}
+	return res
+}
+
+// A bench wraps a chip into a circuit with inputs and outputs that can be
+// accessed by name.
+//
+type bench struct {
+	name  string
+	c     *hwsim.Circuit
+	ins   []*port
+	outs  []*port
+	ports map[string]*port
+}
+
+func newBench(name string, chip hwsim.NewPartFn) (*bench, error) {
+	spec := chip("").PartSpec
+	b := &bench{
+		name:  name,
+		ins:   ports(spec.Inputs),
+		outs:  ports(spec.Outputs),
+		ports: make(map[string]*port),
+	}
+	var parts []hwsim.Part
+	var conns []string
+	for _, p := range b.ins {
+		p := p
+		if p.bits == 1 && !strings.ContainsRune(p.name, '[') {
+			parts = append(parts, hwsim.Input(func() bool { return p.v != 0 })("out="+p.name))
+		} else {
+			parts = append(parts, hwsim.InputN(p.bits, func() uint64 { return p.v })("out="+p.name))
+		}
+		conns = append(conns, p.name+"="+p.name)
+		b.ports[p.name] = p
+	}
+	for _, p := range b.outs {
+		p := p
+		if p.bits == 1 && !strings.ContainsRune(p.name, '[') {
+			parts = append(parts, hwsim.Output(func(v bool) {
+				p.v = 0
+				if v {
+					p.v = 1
+				}
+			})("in="+p.name))
+		} else {
+			parts = append(parts, hwsim.OutputN(p.bits, func(v uint64) { p.v = v })("in="+p.name))
+		}
+		conns = append(conns, p.name+"="+p.name)
+		b.ports[p.name] = p
+	}
+	parts = append(parts, chip(strings.Join(conns, ", ")))
+	c, err := hwsim.NewCircuit(parts...)
+	if err != nil {
+		return nil, err
+	}
+	b.c = c
+	return b, nil
+}
+
+func (b *bench) set(name string, v uint64) error {
+	p := b.ports[name]
+	if p == nil {
+		return errors.Errorf("unknown input %s", name)
+	}
+	if !b.isInput(p) {
+		return errors.Errorf("%s is not an input", name)
+	}
+	if p.bits < 64 {
+		v &= 1<<uint(p.bits) - 1
+	}
+	p.v = v
+	return nil
+}
+
+func (b *bench) isInput(p *port) bool {
+	for _, i := range b.ins {
+		if i == p {
+			return true
+		}
+	}
+	return false
+}
+
+// time returns the current simulation time in the nand2tetris format: the
+// number of elapsed clock cycles, followed by a '+' after a tick.
+//
+func (b *bench) time() string {
+	t := strconv.FormatUint(b.c.Ticks()/2, 10)
+	if b.c.Ticks()&1 != 0 {
+		t += "+"
+	}
+	return t
+}
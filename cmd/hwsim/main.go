@@ -0,0 +1,175 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+// Command hwsim loads a chip from an HDL or JSON netlist file and runs it.
+//
+// Usage:
+//
+//	hwsim [flags] [file]
+//
+// The chip is loaded from file, which is either a nand2tetris style HDL file
+// or a JSON netlist (see package internal/netlist for the formats). Parts not
+// found in the file or in the built-in library are loaded from files named
+// after them (e.g. Xor.hdl) in the same directory.
+//
+// The chip can be driven in several ways:
+//
+//	-tst script	runs a nand2tetris test script. The file argument can be
+//			omitted if the script loads the chip with a load command.
+//	-csv vectors	reads per-cycle input vectors from a CSV file (or stdin
+//			if vectors is "-"). The header row names the inputs to drive,
+//			each following row holds decimal, hex (0x) or binary (0b)
+//			values. One line of outputs is printed per cycle.
+//	-cycles n	runs n clock cycles with all inputs set to 0.
+//	-i		starts an interactive shell to step through the
+//			simulation, inspect wires and save or restore its state.
+//
+// With -csv or -cycles, the -vcd flag writes the state of all inputs and
+// outputs at every half clock cycle to a VCD file.
+//
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/db47h/hwsim/internal/netlist"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "hwsim:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("hwsim", flag.ContinueOnError)
+	var (
+		chip   = fs.String("chip", "", "name of the chip to run (default: last chip in file)")
+		tst    = fs.String("tst", "", "run test `script`")
+		vecs   = fs.String("csv", "", "read input vectors from CSV `file` (- for stdin)")
+		cycles = fs.Int("cycles", 0, "run `n` clock cycles")
+		vcdOut = fs.String("vcd", "", "write a value change dump to `file`")
+		base   = fs.Int("base", 10, "numeric base for printed values (2, 10 or 16)")
+		inter  = fs.Bool("i", false, "start an interactive shell")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: hwsim [flags] [file.hdl|file.json]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("too many arguments")
+	}
+
+	var b *bench
+	if fs.NArg() == 1 {
+		var err error
+		if b, err = load(fs.Arg(0), *chip); err != nil {
+			return err
+		}
+	}
+
+	if *tst != "" {
+		return runScript(*tst, b, stdout)
+	}
+
+	if b == nil {
+		fs.Usage()
+		return errors.New("no chip file specified")
+	}
+
+	if *inter {
+		return runREPL(b, stdin, stdout, *base)
+	}
+
+	var v *vcd
+	if *vcdOut != "" {
+		f, err := os.Create(*vcdOut)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		v = newVCD(f, b)
+		defer v.flush()
+	}
+
+	t := newTable(stdout, b, *base)
+	if *vecs != "" {
+		r := stdin
+		if *vecs != "-" {
+			f, err := os.Open(*vecs)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := runVectors(b, r, t, v); err != nil {
+			return err
+		}
+	} else {
+		n := *cycles
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			step(b, t, v)
+		}
+	}
+	return t.flush()
+}
+
+// load loads the named chip from the given file. If name is empty, the last
+// chip declared in the file is used.
+//
+func load(file, name string) (*bench, error) {
+	lib := netlist.NewLibrary(filepath.Dir(file))
+	decls, err := lib.LoadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		if len(decls) == 0 {
+			return nil, errors.Errorf("%s: no chip declared", file)
+		}
+		name = decls[len(decls)-1].Name
+	}
+	p, err := lib.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return newBench(name, p)
+}
+
+func runScript(name string, b *bench, stdout io.Writer) (err error) {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	cmds, err := parseScript(string(src))
+	if err != nil {
+		return errors.Wrap(err, name)
+	}
+	s := &script{
+		dir:  filepath.Dir(name),
+		load: func(f string) (*bench, error) { return load(f, "") },
+		b:    b,
+		out:  stdout,
+		echo: stdout,
+	}
+	defer func() {
+		if cerr := s.close(); err == nil {
+			err = errors.Wrap(cerr, name)
+		}
+	}()
+	return errors.Wrap(s.run(cmds), name)
+}
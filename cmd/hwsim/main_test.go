@@ -0,0 +1,118 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const xorHDL = `// Xor gate
+CHIP Xor {
+	IN a, b;
+	OUT out;
+	PARTS:
+	Nand(a=a, b=b, out=nab); /* inputs */
+	Nand(a=a, b=nab, out=o1);
+	Nand(a=nab, b=b, out=o2);
+	Nand(a=o1, b=o2, out=out);
+}
+
+CHIP Reg2 {
+	IN in[2], load;
+	OUT out[2];
+	PARTS:
+	Mux(a=q0, b=in[0], sel=load, out=d0);
+	Mux(a=q1, b=in[1], sel=load, out=d1);
+	DFF(in=d0, out=q0, out=out[0]);
+	DFF(in=d1, out=q1, out=out[1]);
+}
+`
+
+const xorTst = `compare-to Xor.cmp,
+output-list a%B3.1.3 b%B3.1.3 out%B3.1.3;
+set a 0, set b 0, eval, output;
+set a 0, set b 1, eval, output;
+repeat 2 {
+	set a 1, eval, output;
+	set b %B0;
+}
+`
+
+const xorCmp = `|   a   |   b   |  out  |
+|   0   |   0   |   0   |
+|   0   |   1   |   1   |
+|   1   |   1   |   0   |
+|   1   |   0   |   1   |
+`
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for n, c := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, n), []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_runScript(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Chips.hdl": xorHDL,
+		"Xor.tst":   xorTst,
+		"Xor.cmp":   xorCmp,
+	})
+	var out strings.Builder
+	// Chips.hdl declares Reg2 last: load the Xor chip explicitly.
+	b, err := load(filepath.Join(dir, "Chips.hdl"), "Xor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = runScript(filepath.Join(dir, "Xor.tst"), b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != xorCmp {
+		t.Fatalf("got:\n%s\nexpected:\n%s", out.String(), xorCmp)
+	}
+}
+
+func Test_runVectors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"Chips.hdl": xorHDL})
+	var out strings.Builder
+	err := run([]string{"-csv", "-", filepath.Join(dir, "Chips.hdl")},
+		strings.NewReader("in, load\n3, 1\n0b01, 0\n0x1, 1\n"), &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := " cycle in load out\n" +
+		"     1  3    1   3\n" +
+		"     2  1    0   3\n" +
+		"     3  1    1   1\n"
+	if out.String() != exp {
+		t.Fatalf("got:\n%s\nexpected:\n%s", out.String(), exp)
+	}
+}
+
+func Test_runREPL(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"Chips.hdl": xorHDL})
+	b, err := load(filepath.Join(dir, "Chips.hdl"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	in := "set in 3\nset load 1\nwatch out Reg2.q0\nstep\nsnapshot\n" +
+		"set in 0\nstep 2\nprint out Reg2.d1\nrestore\nprint out in\nstep\nbogus\n"
+	if err = runREPL(b, strings.NewReader(in), &out, 10); err != nil {
+		t.Fatal(err)
+	}
+	exp := "> > > > 1 out=3 Reg2.q0=1\n" +
+		"> > > 3 out=0 Reg2.q0=0\n" +
+		"> out = 0\nReg2.d1 = 0\n" +
+		"> > out = 3\nin = 3\n" +
+		"> 2 out=3 Reg2.q0=1\n" +
+		"> error: unknown command \"bogus\", type help for a list of commands\n> \n"
+	if out.String() != exp {
+		t.Fatalf("got:\n%s\nexpected:\n%s", out.String(), exp)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/db47h/hwsim"
+	"github.com/pkg/errors"
+)
+
+const replHelp = `Commands:
+  set NAME VALUE     set input NAME to VALUE (decimal, 0x hex or 0b binary)
+  tick | tock        run a half clock cycle
+  eval               re-evaluate the current half clock cycle
+  step [N]           run N clock cycles (default 1)
+  print NAME...      print the value of inputs, outputs or wires
+  watch NAME...      print the value of NAME after each tick, tock or step
+  unwatch [NAME...]  stop watching NAME (default: all)
+  wires [PREFIX]     list wire names starting with PREFIX
+  snapshot [NAME]    save the state of the circuit
+  restore [NAME]     restore a saved state
+  stats              print circuit statistics
+  help               print this help
+  quit               exit
+`
+
+// A repl is an interactive shell on a bench.
+//
+type repl struct {
+	b       *bench
+	out     io.Writer
+	base    int
+	watch   []string
+	snaps   map[string]*snapshot
+	elapsed time.Duration // time spent running the simulation
+}
+
+// A snapshot is a saved state of the circuit and of the bench ports. The
+// ports hold the input values set by the user and the output values of the
+// last simulation step, which are not part of the circuit.
+//
+type snapshot struct {
+	s     *hwsim.Snapshot
+	ports map[*port]uint64
+}
+
+func runREPL(b *bench, in io.Reader, out io.Writer, base int) error {
+	r := &repl{b: b, out: out, base: base, snaps: make(map[string]*snapshot)}
+	s := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !s.Scan() {
+			fmt.Fprintln(out)
+			return s.Err()
+		}
+		args := strings.Fields(s.Text())
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "quit" || args[0] == "exit" {
+			return nil
+		}
+		if err := r.exec(args[0], args[1:]); err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+	}
+}
+
+func (r *repl) exec(cmd string, args []string) error {
+	switch cmd {
+	case "set":
+		if len(args) != 2 {
+			return errors.New("usage: set NAME VALUE")
+		}
+		v, err := parseInt(args[1])
+		if err != nil {
+			return err
+		}
+		return r.b.set(args[0], v)
+	case "tick", "tock", "eval":
+		if len(args) != 0 {
+			return errors.New("usage: " + cmd)
+		}
+		t := time.Now()
+		switch cmd {
+		case "tick":
+			r.b.c.Tick()
+		case "tock":
+			r.b.c.Tock()
+		default:
+			r.b.c.Eval()
+		}
+		r.elapsed += time.Since(t)
+		return r.printWatched()
+	case "step":
+		n := 1
+		if len(args) > 1 {
+			return errors.New("usage: step [N]")
+		}
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+				return errors.Errorf("invalid step count %q", args[0])
+			}
+		}
+		t := time.Now()
+		for i := 0; i < n; i++ {
+			r.b.c.TickTock()
+		}
+		r.elapsed += time.Since(t)
+		return r.printWatched()
+	case "print":
+		if len(args) == 0 {
+			return errors.New("usage: print NAME...")
+		}
+		for _, n := range args {
+			v, err := r.value(n)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(r.out, "%s = %s\n", n, v)
+		}
+	case "watch":
+		if len(args) == 0 {
+			return errors.New("usage: watch NAME...")
+		}
+		for _, n := range args {
+			if _, err := r.value(n); err != nil {
+				return err
+			}
+			r.watch = append(r.watch, n)
+		}
+	case "unwatch":
+		if len(args) == 0 {
+			r.watch = nil
+			return nil
+		}
+		for _, n := range args {
+			for i := 0; i < len(r.watch); i++ {
+				if r.watch[i] == n {
+					r.watch = append(r.watch[:i], r.watch[i+1:]...)
+					i--
+				}
+			}
+		}
+	case "wires":
+		if len(args) > 1 {
+			return errors.New("usage: wires [PREFIX]")
+		}
+		for _, n := range r.b.c.WireNames() {
+			if len(args) == 0 || strings.HasPrefix(n, args[0]) {
+				fmt.Fprintln(r.out, n)
+			}
+		}
+	case "snapshot", "restore":
+		if len(args) > 1 {
+			return errors.New("usage: " + cmd + " [NAME]")
+		}
+		name := ""
+		if len(args) == 1 {
+			name = args[0]
+		}
+		if cmd == "snapshot" {
+			s := &snapshot{s: r.b.c.Snapshot(), ports: make(map[*port]uint64, len(r.b.ports))}
+			for _, p := range r.b.ports {
+				s.ports[p] = p.v
+			}
+			r.snaps[name] = s
+			return nil
+		}
+		s := r.snaps[name]
+		if s == nil {
+			return errors.Errorf("no such snapshot %q", name)
+		}
+		r.b.c.Restore(s.s)
+		for p, v := range s.ports {
+			p.v = v
+		}
+	case "stats":
+		c := r.b.c
+		fmt.Fprintf(r.out, "components: %d\nwires: %d\ncycles: %s\n", c.ComponentCount(), c.WireCount(), r.b.time())
+		if r.elapsed > 0 {
+			fmt.Fprintf(r.out, "run time: %v (%.2f Hz)\n", r.elapsed, float64(c.Ticks())/2/r.elapsed.Seconds())
+		}
+	case "help":
+		fmt.Fprint(r.out, replHelp)
+	default:
+		return errors.Errorf("unknown command %q, type help for a list of commands", cmd)
+	}
+	return nil
+}
+
+// value returns the formatted value of an input or output port, or of a wire
+// or bus within the circuit.
+//
+func (r *repl) value(name string) (string, error) {
+	if p := r.b.ports[name]; p != nil {
+		return formatValue(p.v, r.base), nil
+	}
+	c := r.b.c
+	if w := c.Wire(name); w != nil {
+		if w.Value() {
+			return "1", nil
+		}
+		return "0", nil
+	}
+	var v uint64
+	bits := 0
+	for ; bits < 64; bits++ {
+		w := c.Wire(name + "[" + strconv.Itoa(bits) + "]")
+		if w == nil {
+			break
+		}
+		if w.Value() {
+			v |= 1 << uint(bits)
+		}
+	}
+	if bits == 0 {
+		return "", errors.Errorf("no such wire %s", name)
+	}
+	return formatValue(v, r.base), nil
+}
+
+func (r *repl) printWatched() error {
+	if len(r.watch) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	b.WriteString(r.b.time())
+	for _, n := range r.watch {
+		v, err := r.value(n)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, " %s=%s", n, v)
+	}
+	fmt.Fprintln(r.out, b.String())
+	return nil
+}
@@ -0,0 +1,463 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// A command is a single statement in a test script.
+//
+type command struct {
+	name string
+	args []string
+	body []*command // repeat block
+	line int
+}
+
+// parseScript parses a test script in the nand2tetris format. Commands are
+// separated by ',' or ';'. Supported commands are:
+//
+//	load file, output-file file, compare-to file, output-list col..., set name
+//	value, eval, tick, tock, output, echo "text", clear-echo and
+//	repeat n { ... }.
+//
+func parseScript(src string) ([]*command, error) {
+	p := &scriptParser{src: []rune(src), line: 1}
+	cmds, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected '}'")
+	}
+	return cmds, nil
+}
+
+type scriptParser struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func (p *scriptParser) eof() bool { return p.pos >= len(p.src) }
+
+func (p *scriptParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("line %d: "+format, append([]interface{}{p.line}, args...)...)
+}
+
+func (p *scriptParser) hasPrefix(s string) bool {
+	return strings.HasPrefix(string(p.src[p.pos:min(p.pos+len(s), len(p.src))]), s)
+}
+
+func (p *scriptParser) next() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+	}
+	return r
+}
+
+// skip skips white space and comments.
+//
+func (p *scriptParser) skip() {
+	for !p.eof() {
+		switch {
+		case unicode.IsSpace(p.src[p.pos]):
+			p.next()
+		case p.hasPrefix("//"):
+			for !p.eof() && p.src[p.pos] != '\n' {
+				p.next()
+			}
+		case p.hasPrefix("/*"):
+			p.pos += 2
+			for !p.eof() && !p.hasPrefix("*/") {
+				p.next()
+			}
+			p.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+// word reads a single word or quoted string.
+//
+func (p *scriptParser) word() (string, error) {
+	var b strings.Builder
+	if p.src[p.pos] == '"' {
+		p.next()
+		for !p.eof() && p.src[p.pos] != '"' {
+			b.WriteRune(p.next())
+		}
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		p.next()
+		return b.String(), nil
+	}
+	for !p.eof() {
+		r := p.src[p.pos]
+		if unicode.IsSpace(r) || strings.ContainsRune(",;{}", r) || p.hasPrefix("//") || p.hasPrefix("/*") {
+			break
+		}
+		b.WriteRune(p.next())
+	}
+	return b.String(), nil
+}
+
+func (p *scriptParser) block() ([]*command, error) {
+	var cmds []*command
+	for {
+		p.skip()
+		if p.eof() || p.src[p.pos] == '}' {
+			return cmds, nil
+		}
+		if r := p.src[p.pos]; r == ',' || r == ';' {
+			p.next()
+			continue
+		}
+		c := &command{line: p.line}
+		for {
+			p.skip()
+			if p.eof() {
+				break
+			}
+			r := p.src[p.pos]
+			if r == ',' || r == ';' || r == '}' {
+				break
+			}
+			if r == '{' {
+				p.next()
+				body, err := p.block()
+				if err != nil {
+					return nil, err
+				}
+				if p.eof() {
+					return nil, p.errorf("missing '}'")
+				}
+				p.next()
+				c.body = body
+				break
+			}
+			w, err := p.word()
+			if err != nil {
+				return nil, err
+			}
+			if c.name == "" {
+				c.name = w
+			} else {
+				c.args = append(c.args, w)
+			}
+		}
+		cmds = append(cmds, c)
+	}
+}
+
+
+// A column is an output-list entry like "a%B3.1.3".
+//
+type column struct {
+	name              string
+	format            byte
+	lpad, width, rpad int
+}
+
+func parseColumn(s string) (column, error) {
+	c := column{name: s, format: 'B', lpad: 1, width: 1, rpad: 1}
+	i := strings.IndexRune(s, '%')
+	if i < 0 {
+		return c, nil
+	}
+	c.name = s[:i]
+	f := s[i+1:]
+	if len(f) == 0 {
+		return c, errors.Errorf("invalid output format %q", s)
+	}
+	c.format = f[0]
+	if !strings.ContainsRune("BDXS", rune(c.format)) {
+		return c, errors.Errorf("invalid output format %q", s)
+	}
+	if f = f[1:]; f == "" {
+		return c, nil
+	}
+	n := strings.Split(f, ".")
+	if len(n) != 3 {
+		return c, errors.Errorf("invalid output format %q", s)
+	}
+	var err error
+	for i, p := range []*int{&c.lpad, &c.width, &c.rpad} {
+		if *p, err = strconv.Atoi(n[i]); err != nil || *p < 0 {
+			return c, errors.Errorf("invalid output format %q", s)
+		}
+	}
+	return c, nil
+}
+
+func center(s string, w int) string {
+	if len(s) >= w {
+		return s[:w]
+	}
+	l := (w - len(s)) / 2
+	return strings.Repeat(" ", l) + s + strings.Repeat(" ", w-len(s)-l)
+}
+
+// parseValue parses a value in the nand2tetris format: %B0101, %XFF, %D-1 or
+// a plain decimal value.
+//
+func parseValue(s string) (uint64, error) {
+	base := 10
+	if strings.HasPrefix(s, "%") && len(s) > 1 {
+		switch s[1] {
+		case 'B':
+			base = 2
+		case 'X':
+			base = 16
+		case 'D':
+		default:
+			return 0, errors.Errorf("invalid value %q", s)
+		}
+		s = s[2:]
+	}
+	if base == 10 && strings.HasPrefix(s, "-") {
+		v, err := strconv.ParseInt(s, 10, 64)
+		return uint64(v), err
+	}
+	return strconv.ParseUint(s, base, 64)
+}
+
+// A script runs a test script.
+//
+type script struct {
+	dir  string
+	load func(string) (*bench, error)
+	b    *bench
+	out  io.Writer
+	file io.Closer // file opened by output-file
+	cols []column
+	cmp  *bufio.Scanner
+	line int // output line number
+	echo io.Writer
+}
+
+// close closes the file opened by the last output-file command, if any.
+//
+func (s *script) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *script) run(cmds []*command) error {
+	for _, c := range cmds {
+		if err := s.exec(c); err != nil {
+			return errors.Wrapf(err, "line %d: %s", c.line, c.name)
+		}
+	}
+	return nil
+}
+
+func (s *script) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+func (s *script) exec(c *command) error {
+	if c.name != "load" && c.name != "echo" && c.name != "clear-echo" && c.name != "repeat" && s.b == nil {
+		return errors.New("no chip loaded")
+	}
+	switch c.name {
+	case "load":
+		if len(c.args) != 1 {
+			return errors.New("expected a single file name")
+		}
+		b, err := s.load(s.path(c.args[0]))
+		if err != nil {
+			return err
+		}
+		s.b = b
+	case "output-file":
+		if len(c.args) != 1 {
+			return errors.New("expected a single file name")
+		}
+		if err := s.close(); err != nil {
+			return err
+		}
+		f, err := os.Create(s.path(c.args[0]))
+		if err != nil {
+			return err
+		}
+		s.out, s.file = f, f
+	case "compare-to":
+		if len(c.args) != 1 {
+			return errors.New("expected a single file name")
+		}
+		b, err := ioutil.ReadFile(s.path(c.args[0]))
+		if err != nil {
+			return err
+		}
+		s.cmp = bufio.NewScanner(strings.NewReader(string(b)))
+	case "output-list":
+		s.cols = s.cols[:0]
+		for _, a := range c.args {
+			col, err := parseColumn(a)
+			if err != nil {
+				return err
+			}
+			if col.name != "time" && s.b.ports[col.name] == nil {
+				return errors.Errorf("unknown pin %s", col.name)
+			}
+			s.cols = append(s.cols, col)
+		}
+		return s.header()
+	case "set":
+		if len(c.args) != 2 {
+			return errors.New("expected pin name and value")
+		}
+		v, err := parseValue(c.args[1])
+		if err != nil {
+			return err
+		}
+		return s.b.set(c.args[0], v)
+	case "eval":
+		s.b.c.Eval()
+	case "tick":
+		s.b.c.Tick()
+	case "tock":
+		s.b.c.Tock()
+	case "output":
+		if s.cols == nil {
+			s.defaultColumns()
+			if err := s.header(); err != nil {
+				return err
+			}
+		}
+		return s.output()
+	case "echo":
+		fmt.Fprintln(s.echo, strings.Join(c.args, " "))
+	case "clear-echo":
+	case "repeat":
+		n := -1
+		if len(c.args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(c.args[0]); err != nil {
+				return err
+			}
+		}
+		if n < 0 || len(c.args) > 1 {
+			return errors.New("repeat needs a positive repeat count")
+		}
+		for i := 0; i < n; i++ {
+			if err := s.run(c.body); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("unsupported command")
+	}
+	return nil
+}
+
+func (s *script) defaultColumns() {
+	for _, p := range s.b.ins {
+		s.cols = append(s.cols, column{name: p.name, format: 'B', lpad: 1, width: p.bits, rpad: 1})
+	}
+	for _, p := range s.b.outs {
+		s.cols = append(s.cols, column{name: p.name, format: 'B', lpad: 1, width: p.bits, rpad: 1})
+	}
+}
+
+func (s *script) header() error {
+	var b strings.Builder
+	for _, c := range s.cols {
+		b.WriteRune('|')
+		b.WriteString(center(c.name, c.lpad+c.width+c.rpad))
+	}
+	b.WriteRune('|')
+	return s.writeLine(b.String())
+}
+
+func (s *script) output() error {
+	var b strings.Builder
+	for _, c := range s.cols {
+		b.WriteRune('|')
+		b.WriteString(strings.Repeat(" ", c.lpad))
+		b.WriteString(s.format(c))
+		b.WriteString(strings.Repeat(" ", c.rpad))
+	}
+	b.WriteRune('|')
+	return s.writeLine(b.String())
+}
+
+func (s *script) format(c column) string {
+	var v string
+	if c.name == "time" {
+		v = s.b.time()
+	} else {
+		p := s.b.ports[c.name]
+		switch c.format {
+		case 'B':
+			v = strconv.FormatUint(p.v, 2)
+		case 'X':
+			v = strings.ToUpper(strconv.FormatUint(p.v, 16))
+		case 'D', 'S':
+			x := int64(p.v)
+			if p.bits < 64 && p.v&(1<<uint(p.bits-1)) != 0 {
+				x -= 1 << uint(p.bits)
+			}
+			v = strconv.FormatInt(x, 10)
+		}
+		switch c.format {
+		case 'B', 'X':
+			if len(v) < c.width {
+				v = strings.Repeat("0", c.width-len(v)) + v
+			}
+			return v[len(v)-c.width:]
+		}
+	}
+	if len(v) > c.width {
+		return v[:c.width]
+	}
+	if c.format == 'S' {
+		return v + strings.Repeat(" ", c.width-len(v))
+	}
+	return strings.Repeat(" ", c.width-len(v)) + v
+}
+
+// writeLine writes an output line and compares it to the next line of the
+// compare file, if any. White space is ignored in comparisons.
+//
+func (s *script) writeLine(l string) error {
+	s.line++
+	if _, err := fmt.Fprintln(s.out, l); err != nil {
+		return err
+	}
+	if s.cmp == nil {
+		return nil
+	}
+	if !s.cmp.Scan() {
+		return errors.Errorf("comparison failure at line %d: unexpected end of compare file", s.line)
+	}
+	strip := func(s string) string { return strings.Join(strings.Fields(s), "") }
+	if exp := s.cmp.Text(); strip(exp) != strip(l) {
+		return errors.Errorf("comparison failure at line %d:\nexpected %s\ngot      %s", s.line, exp, l)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// vcd writes a Value Change Dump of a bench's ports. Each half clock cycle
+// is one time unit.
+//
+type vcd struct {
+	w    *bufio.Writer
+	b    *bench
+	ps   []*port
+	ids  []string
+	last []uint64
+	t    int
+}
+
+func newVCD(w io.Writer, b *bench) *vcd {
+	v := &vcd{w: bufio.NewWriter(w), b: b, t: -1}
+	v.ps = append(append(v.ps, b.ins...), b.outs...)
+	fmt.Fprintln(v.w, "$timescale 1ns $end")
+	fmt.Fprintf(v.w, "$scope module %s $end\n", b.name)
+	fmt.Fprintln(v.w, "$var wire 1 ! clk $end")
+	for i, p := range v.ps {
+		id := vcdID(i + 1)
+		v.ids = append(v.ids, id)
+		fmt.Fprintf(v.w, "$var wire %d %s %s $end\n", p.bits, id, p.name)
+	}
+	fmt.Fprintln(v.w, "$upscope $end")
+	fmt.Fprintln(v.w, "$enddefinitions $end")
+	v.last = make([]uint64, len(v.ps))
+	return v
+}
+
+// vcdID returns the VCD identifier code for the n-th variable.
+//
+func vcdID(n int) string {
+	const first, last = '!', '~'
+	var b []byte
+	for {
+		b = append(b, byte(first+n%(last-first+1)))
+		n /= last - first + 1
+		if n == 0 {
+			return string(b)
+		}
+	}
+}
+
+// dump records the current state of all ports.
+//
+func (v *vcd) dump() {
+	v.t++
+	fmt.Fprintf(v.w, "#%d\n", v.t)
+	// the clock is high after a Tick
+	fmt.Fprintf(v.w, "%d!\n", v.b.c.Ticks()&1)
+	for i, p := range v.ps {
+		if v.t > 0 && v.last[i] == p.v {
+			continue
+		}
+		v.last[i] = p.v
+		if p.bits == 1 {
+			fmt.Fprintf(v.w, "%d%s\n", p.v, v.ids[i])
+		} else {
+			fmt.Fprintf(v.w, "b%s %s\n", strconv.FormatUint(p.v, 2), v.ids[i])
+		}
+	}
+}
+
+func (v *vcd) flush() error {
+	fmt.Fprintf(v.w, "#%d\n", v.t+1)
+	return v.w.Flush()
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// parseInt parses a decimal, hexadecimal (0x) or binary (0b) value.
+//
+func parseInt(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		return strconv.ParseUint(s[2:], 16, 64)
+	case strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0B"):
+		return strconv.ParseUint(s[2:], 2, 64)
+	case strings.HasPrefix(s, "-"):
+		v, err := strconv.ParseInt(s, 10, 64)
+		return uint64(v), err
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// A table prints the state of a bench's ports, one line per clock cycle.
+//
+type table struct {
+	b    *bench
+	w    *tabwriter.Writer
+	base int
+}
+
+func newTable(w io.Writer, b *bench, base int) *table {
+	t := &table{b: b, w: tabwriter.NewWriter(w, 0, 8, 1, ' ', tabwriter.AlignRight), base: base}
+	fmt.Fprint(t.w, "cycle\t")
+	for _, p := range b.ins {
+		fmt.Fprintf(t.w, "%s\t", p.name)
+	}
+	for _, p := range b.outs {
+		fmt.Fprintf(t.w, "%s\t", p.name)
+	}
+	fmt.Fprintln(t.w)
+	return t
+}
+
+func (t *table) row() {
+	fmt.Fprintf(t.w, "%d\t", t.b.c.Ticks()/2)
+	for _, p := range t.b.ins {
+		fmt.Fprintf(t.w, "%s\t", formatValue(p.v, t.base))
+	}
+	for _, p := range t.b.outs {
+		fmt.Fprintf(t.w, "%s\t", formatValue(p.v, t.base))
+	}
+	fmt.Fprintln(t.w)
+}
+
+func (t *table) flush() error {
+	return t.w.Flush()
+}
+
+func formatValue(v uint64, base int) string {
+	switch base {
+	case 2:
+		return "0b" + strconv.FormatUint(v, 2)
+	case 16:
+		return "0x" + strconv.FormatUint(v, 16)
+	}
+	return strconv.FormatUint(v, 10)
+}
+
+// runVectors reads per-cycle input vectors in CSV format from r and runs one
+// clock cycle per record. The first record is a header with the names of the
+// inputs to drive.
+//
+func runVectors(b *bench, r io.Reader, t *table, v *vcd) error {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	hdr, err := cr.Read()
+	if err != nil {
+		return errors.Wrap(err, "failed to read CSV header")
+	}
+	for _, n := range hdr {
+		if p := b.ports[n]; p == nil || !b.isInput(p) {
+			return errors.Errorf("unknown input %s in CSV header", n)
+		}
+	}
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(rec) != len(hdr) {
+			line, _ := cr.FieldPos(0)
+			return errors.Errorf("line %d: expected %d values, got %d", line, len(hdr), len(rec))
+		}
+		for i, s := range rec {
+			x, err := parseInt(s)
+			if err != nil {
+				line, _ := cr.FieldPos(i)
+				return errors.Wrapf(err, "line %d", line)
+			}
+			if err = b.set(hdr[i], x); err != nil {
+				return err
+			}
+		}
+		step(b, t, v)
+	}
+}
+
+// step runs a single clock cycle and records the results.
+//
+func step(b *bench, t *table, v *vcd) {
+	b.c.Tick()
+	if v != nil {
+		v.dump()
+	}
+	b.c.Tock()
+	if v != nil {
+		v.dump()
+	}
+	if t != nil {
+		t.row()
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"github.com/pkg/errors"
+)
+
+// sortComponents returns the given components sorted in topological order:
+// components come after the components that drive their inputs. Components
+// for which source returns true are excluded from the result and their
+// outputs are considered as primary inputs.
+//
+// sortComponents returns an error if there is a loop in the wiring.
+//
+func sortComponents(comps []*Component, source func(*Component) bool) ([]*Component, error) {
+	var cs []*Component
+	driver := make(map[*Wire]int)
+	for _, c := range comps {
+		if source(c) {
+			continue
+		}
+		for _, w := range c.Outputs {
+			if w != nil {
+				driver[w] = len(cs)
+			}
+		}
+		cs = append(cs, c)
+	}
+
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make([]int, len(cs))
+	sorted := make([]*Component, 0, len(cs))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return errors.Errorf("%s: wiring loop detected", cs[i].Name)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, w := range cs[i].Inputs {
+			if d, ok := driver[w]; ok {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = done
+		sorted = append(sorted, cs[i])
+		return nil
+	}
+	for i := range cs {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// A step is a step in the compiled evaluation program of a circuit. in and
+// out are the wires connected to the component's inputs and outputs.
+//
+// Components with at most 6 inputs and a Bitwise function are evaluated from
+// a lookup table: bit n of lut[i] is the value of output i when the value of
+// the inputs, input 0 being the LSB, is n. Other components are updated with
+// their Update method.
+//
+type step struct {
+	u       Updater
+	in, out []*Wire
+	lut     []uint64
+}
+
+func (s *step) run(clk bool) {
+	if s.lut == nil {
+		s.u.Update(clk)
+		return
+	}
+	// inputs are already up to date: read them directly
+	var n uint
+	for i, w := range s.in {
+		if w.value {
+			n |= 1 << uint(i)
+		}
+	}
+	for i, w := range s.out {
+		w.clk, w.value = clk, s.lut[i]&(1<<n) != 0
+	}
+}
+
+// lanePatterns are the values of the inputs of a component with 6 inputs in
+// each lane of a Batch, such that the value of the inputs in lane n is n.
+//
+var lanePatterns = [...]uint64{
+	0xAAAAAAAAAAAAAAAA,
+	0xCCCCCCCCCCCCCCCC,
+	0xF0F0F0F0F0F0F0F0,
+	0xFF00FF00FF00FF00,
+	0xFFFF0000FFFF0000,
+	0xFFFFFFFF00000000,
+}
+
+// lut returns the lookup tables of the outputs of a combinational component
+// with a Bitwise function.
+//
+func lut(cp *Component) []uint64 {
+	iv := make([]uint64, len(cp.Inputs))
+	copy(iv, lanePatterns[:])
+	ov := make([]uint64, len(cp.Outputs))
+	cp.Spec.Bitwise(iv, ov)
+	return ov
+}
+
+// compile builds the evaluation program of the circuit: the combinational
+// components sorted in topological order, so that they can be updated in
+// sequence after the PostUpdaters without pulling their inputs recursively.
+// If there are wiring loops, the circuit is not compiled and components are
+// updated on demand.
+//
+func (c *Circuit) compile() {
+	comps, err := sortComponents(c.comps, func(cp *Component) bool {
+		_, ok := cp.Updater.(PostUpdater)
+		return ok
+	})
+	if err != nil {
+		return
+	}
+	c.prog = make([]step, len(comps))
+	for i, cp := range comps {
+		s := &c.prog[i]
+		s.u = cp.Updater
+		s.in = make([]*Wire, len(cp.Inputs))
+		for j, w := range cp.Inputs {
+			if w == nil {
+				w = c.wires[cstFalse]
+			}
+			s.in[j] = w
+		}
+		s.out = cp.Outputs
+		if cp.Spec.Bitwise == nil || len(cp.Inputs) > len(lanePatterns) {
+			continue
+		}
+		s.lut = lut(cp)
+		for _, w := range s.out {
+			if w == nil {
+				// unconnected output, use the regular Update
+				s.lut = nil
+				break
+			}
+		}
+	}
+}
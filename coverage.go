@@ -0,0 +1,153 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coverage is a toggle coverage report. See Circuit.SetCoverage.
+//
+type Coverage struct {
+	Wires []WireCoverage // wires sorted by name
+}
+
+// WireCoverage is the toggle coverage of a single wire.
+//
+type WireCoverage struct {
+	Name    string // canonical wire name
+	Toggles uint64 // number of value changes
+	Seen0   bool   // the wire was seen at 0
+	Seen1   bool   // the wire was seen at 1
+}
+
+// Covered returns true if the wire was seen at both 0 and 1.
+//
+func (w *WireCoverage) Covered() bool {
+	return w.Seen0 && w.Seen1
+}
+
+// Untoggled returns the wires that were not seen at both 0 and 1.
+//
+func (c *Coverage) Untoggled() []WireCoverage {
+	var ws []WireCoverage
+	for _, w := range c.Wires {
+		if !w.Covered() {
+			ws = append(ws, w)
+		}
+	}
+	return ws
+}
+
+// Toggles returns the total number of value changes of all wires. It can be
+// used as a rough estimate of the power consumption of a circuit.
+//
+func (c *Coverage) Toggles() uint64 {
+	var n uint64
+	for _, w := range c.Wires {
+		n += w.Toggles
+	}
+	return n
+}
+
+// String returns a summary of the report followed by the list of wires that
+// never toggled.
+//
+func (c *Coverage) String() string {
+	var b strings.Builder
+	u := c.Untoggled()
+	n := len(c.Wires) - len(u)
+	b.WriteString(strconv.Itoa(n) + "/" + strconv.Itoa(len(c.Wires)) + " wires toggled")
+	if len(c.Wires) > 0 {
+		b.WriteString(" (" + strconv.FormatFloat(float64(n)*100/float64(len(c.Wires)), 'f', 1, 64) + "%)")
+	}
+	b.WriteString(", " + strconv.FormatUint(c.Toggles(), 10) + " toggles\n")
+	for _, w := range u {
+		b.WriteString("\t" + w.Name)
+		switch {
+		case w.Seen0:
+			b.WriteString(" stuck at 0\n")
+		case w.Seen1:
+			b.WriteString(" stuck at 1\n")
+		default:
+			b.WriteString(" never updated\n")
+		}
+	}
+	return b.String()
+}
+
+const (
+	seen0 = 1 << iota
+	seen1
+)
+
+type coverage struct {
+	sampled bool
+	val     []bool
+	toggles []uint64
+	seen    []uint8
+}
+
+func (cv *coverage) sample(ws []*Wire) {
+	for i, w := range ws {
+		v := w.value
+		if cv.sampled && v != cv.val[i] {
+			cv.toggles[i]++
+		}
+		cv.val[i] = v
+		if v {
+			cv.seen[i] |= seen1
+		} else {
+			cv.seen[i] |= seen0
+		}
+	}
+	cv.sampled = true
+}
+
+// SetCoverage enables or disables toggle coverage. When enabled, the value of
+// every wire is sampled at the end of each half clock cycle in order to count
+// value changes and record whether the wire was seen at 0 and 1. Enabling
+// coverage resets the counters.
+//
+func (c *Circuit) SetCoverage(on bool) {
+	if !on {
+		c.cov = nil
+		return
+	}
+	n := len(c.wires)
+	c.cov = &coverage{
+		val:     make([]bool, n),
+		toggles: make([]uint64, n),
+		seen:    make([]uint8, n),
+	}
+}
+
+// Coverage returns the toggle coverage report of the circuit since coverage
+// was enabled, or nil if coverage is not enabled. Constant wires and wires
+// without a name (see Circuit.WireName) are not included in the report.
+//
+func (c *Circuit) Coverage() *Coverage {
+	cv := c.cov
+	if cv == nil {
+		return nil
+	}
+	r := &Coverage{}
+	for i := cstCount; i < len(c.wires); i++ {
+		n := c.WireName(c.wires[i])
+		if n == "" {
+			// temporary wire
+			continue
+		}
+		r.Wires = append(r.Wires, WireCoverage{
+			Name:    n,
+			Toggles: cv.toggles[i],
+			Seen0:   cv.seen[i]&seen0 != 0,
+			Seen1:   cv.seen[i]&seen1 != 0,
+		})
+	}
+	sort.Slice(r.Wires, func(i, j int) bool { return r.Wires[i].Name < r.Wires[j].Name })
+	return r
+}
@@ -0,0 +1,43 @@
+package hwsim_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+func TestCircuit_Coverage(t *testing.T) {
+	var a bool
+	c, err := hwsim.NewCircuit(
+		hwsim.Input(func() bool { return a })("out=a"),
+		hwsim.Input(func() bool { return true })("out=b"),
+		tl.xor("a=a, b=b, out=x"),
+		hwsim.Output(func(bool) {})("in=x"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Coverage() != nil {
+		t.Fatal("coverage enabled by default")
+	}
+	c.SetCoverage(true)
+	for i := 0; i < 10; i++ {
+		a = !a
+		c.TickTock()
+	}
+	cv := c.Coverage()
+	u := cv.Untoggled()
+	// xor.o0 = !(a && !(a && b)) is always true when b is true
+	if len(u) != 2 || u[0].Name != "b" || u[1].Name != "xor.o0" || u[0].Seen0 || !u[0].Seen1 {
+		t.Fatalf("unexpected untoggled wires: %+v", u)
+	}
+	for _, w := range cv.Wires {
+		if w.Name == "a" && w.Toggles != 9 {
+			t.Fatalf("expected 9 toggles for a, got %d", w.Toggles)
+		}
+	}
+	if s := cv.String(); !strings.Contains(s, "\tb stuck at 1\n") {
+		t.Fatalf("unexpected report:\n%s", s)
+	}
+}
@@ -32,7 +32,9 @@ As a result:
 
 	- Most components ignore the clk argument to Updater.Update(clk bool) and
 	  just forward it to the Send/Recv methods of their connected Wires.
-	- Most components like logic gates have no propagation delay.
+	- Most components like logic gates have no propagation delay. An optional
+	  timed simulation mode, where each part has a propagation delay, is
+	  available with Circuit.SetTimed.
 	- Other components like Data Flip-Flops (DFF) have a one clock cycle
 	  propagation delay.
 	- Direct wire loops are forbidden. Loops must go through a DFF or similar
@@ -0,0 +1,48 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A WiringError is an error in the connections of a part, as returned by
+// Chip or ParseConnections, or as a panic value by PartSpec.NewPart.
+//
+// It identifies the part and the connection string where the error was
+// found. Col is the column of the offending token in Conn, so that tools can
+// point at the exact location of the problem:
+//
+//	if we, ok := err.(*hwsim.WiringError); ok && we.Col > 0 {
+//		fmt.Printf("%s\n%*s\n", we.Conn, we.Col, "^")
+//	}
+//
+type WiringError struct {
+	Chip     string // name of the chip being built, if any
+	Part     int    // index of the part in the parts given to Chip, -1 if unknown
+	PartName string // name of the part, if any
+	Conn     string // connection string of the part
+	Col      int    // column of the offending token in Conn, starting at 1, 0 if unknown
+	Err      error  // actual error
+}
+
+func (e *WiringError) Error() string {
+	var b strings.Builder
+	if e.Chip != "" {
+		b.WriteString("chip " + e.Chip + ": ")
+	}
+	if e.Part >= 0 {
+		b.WriteString("part " + strconv.Itoa(e.Part) + " ")
+	}
+	if e.PartName != "" || e.Conn != "" {
+		b.WriteString(e.PartName + "(" + strconv.Quote(e.Conn) + ")")
+		if e.Col > 0 {
+			b.WriteString(" col " + strconv.Itoa(e.Col))
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
@@ -0,0 +1,64 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"github.com/pkg/errors"
+)
+
+// A Fault is a stuck-at fault: the named wire is forced to the StuckAt value
+// regardless of the component driving it.
+//
+type Fault struct {
+	Wire    string
+	StuckAt bool
+}
+
+// String returns the fault in the usual wire/value notation, like "carry/0".
+//
+func (f Fault) String() string {
+	if f.StuckAt {
+		return f.Wire + "/1"
+	}
+	return f.Wire + "/0"
+}
+
+// InjectFault forces the wire named f.Wire to the value f.StuckAt until
+// ClearFaults is called. Any hierarchical wire name accepted by Circuit.Wire
+// can be used, but constant wires cannot be forced.
+//
+// While faults are injected, the circuit is simulated in the default mode:
+// the compiled program, parallel workers and timed mode are disabled.
+//
+func (c *Circuit) InjectFault(f Fault) error {
+	w := c.Wire(f.Wire)
+	if w == nil {
+		return errors.Errorf("no wire named %q", f.Wire)
+	}
+	for i := 0; i < cstCount; i++ {
+		if w == c.wires[i] {
+			return errors.Errorf("cannot inject fault in constant wire %q", f.Wire)
+		}
+	}
+	if c.faults == nil {
+		c.faults = make(map[*Wire]bool)
+	}
+	c.faults[w] = f.StuckAt
+	return nil
+}
+
+// ClearFaults removes all injected faults.
+//
+func (c *Circuit) ClearFaults() {
+	c.faults = nil
+}
+
+// forceFaults sets the value of faulty wires for the half cycle clk. Since the
+// wires are up to date, their source will not update them.
+//
+func (c *Circuit) forceFaults(clk bool) {
+	for w, v := range c.faults {
+		w.clk, w.value = clk, v
+	}
+}
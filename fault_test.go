@@ -0,0 +1,49 @@
+package hwsim_test
+
+import (
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+func TestCircuit_InjectFault(t *testing.T) {
+	var a, b, out bool
+	c, err := hwsim.NewCircuit(
+		hwsim.Input(func() bool { return a })("out=a"),
+		hwsim.Input(func() bool { return b })("out=b"),
+		tl.xor("a=a, b=b, out=x"),
+		tl.dff("in=x, out=q"),
+		hwsim.Output(func(v bool) { out = v })("in=q"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.InjectFault(hwsim.Fault{Wire: "xor.nab", StuckAt: true}); err != nil {
+		t.Fatal(err)
+	}
+	a, b = true, true
+	c.TickTock()
+	// out = !(a && nab) && !(nab && b) = !(a && b)
+	if !out {
+		t.Fatal("xor.nab/1 not injected")
+	}
+	if err = c.InjectFault(hwsim.Fault{Wire: "q", StuckAt: false}); err != nil {
+		t.Fatal(err)
+	}
+	c.TickTock()
+	if out {
+		t.Fatal("q/0 not injected")
+	}
+	c.ClearFaults()
+	c.TickTock()
+	if out {
+		t.Fatalf("xor(1, 1) = %v after clearing faults", out)
+	}
+
+	if err = c.InjectFault(hwsim.Fault{Wire: "nowire"}); err == nil {
+		t.Fatal("no error for unknown wire")
+	}
+	if err = c.InjectFault(hwsim.Fault{Wire: hwsim.True}); err == nil {
+		t.Fatal("no error for constant wire")
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/db47h/hwsim/internal/pins"
+	"github.com/pkg/errors"
+)
+
+// Flatten returns an equivalent version of the given part, built as a single
+// chip that contains only the given primitive parts, like:
+//
+//	nandOnly, err := Flatten(cpu, hwlib.Nand, hwlib.DFF)
+//
+// Chips built with Chip are replaced by their sub-parts, and other parts by
+// their gate-level implementation (see PartSpec.Impl), recursively, until
+// only primitive parts remain. Primitive parts are identified by their
+// PartSpec. If a part has alternative implementations (see
+// PartSpec.AltImpls), the one that can be built with the fewest primitive
+// parts is used. An error is returned if a part that is not a primitive has
+// no gate-level implementation.
+//
+// The wires of the flattened chip are named after the wires of the original
+// chips, prefixed with the names of the part instances that contain them,
+// like "XOR_nab" for the wire nab in the part XOR.
+//
+func Flatten(part NewPartFn, prims ...NewPartFn) (NewPartFn, error) {
+	spec := part("").PartSpec
+	f := &flattener{
+		prims: make(map[*PartSpec]bool, len(prims)),
+		impl:  make(map[*PartSpec]bool),
+		size:  make(map[*PartSpec]int),
+		best:  make(map[*PartSpec]NewPartFn),
+		visit: make(map[*PartSpec]bool),
+		used:  make(map[string]bool),
+		read:  make(map[string]bool),
+		drv:   make(map[string]bool),
+	}
+	for _, p := range prims {
+		f.prims[p("").PartSpec] = true
+	}
+	for _, n := range cstPinNames {
+		f.used[n] = true
+		f.drv[n] = true
+	}
+	m := make(map[string][]string)
+	for _, k := range spec.Inputs {
+		m[k] = []string{f.wire(k)}
+		f.drv[k] = true
+	}
+	for _, k := range spec.Outputs {
+		m[k] = []string{f.wire(k)}
+		f.read[k] = true
+	}
+	if err := f.expand(spec, m, ""); err != nil {
+		return nil, err
+	}
+
+	parts := make([]Part, 0, len(f.parts))
+	for _, p := range f.parts {
+		var cs []string
+		for _, k := range p.spec.Inputs {
+			if ws := p.pins[k]; len(ws) > 0 {
+				w := ws[0]
+				if !f.drv[w] {
+					// undriven chip output in the original part
+					w = False
+				}
+				cs = append(cs, k+"="+w)
+			}
+		}
+		for _, k := range p.spec.Outputs {
+			for _, w := range p.pins[k] {
+				// skip unused wires: Chip rejects them
+				if f.read[w] {
+					cs = append(cs, k+"="+w)
+				}
+			}
+		}
+		parts = append(parts, p.spec.NewPart(strings.Join(cs, ", ")))
+	}
+	return Chip(spec.Name, pins.IOSpec(spec.Inputs), pins.IOSpec(spec.Outputs), parts...)
+}
+
+type flatPart struct {
+	spec *PartSpec
+	pins map[string][]string // pin name -> wire names
+}
+
+type flattener struct {
+	prims map[*PartSpec]bool
+	impl  map[*PartSpec]bool      // parts being replaced by their implementation
+	size  map[*PartSpec]int       // flattened size of parts, see partSize
+	best  map[*PartSpec]NewPartFn // smallest implementation of parts
+	visit map[*PartSpec]bool      // parts being sized
+	parts []flatPart
+	used  map[string]bool // wire names in use
+	read  map[string]bool // wires connected to part inputs or chip outputs
+	drv   map[string]bool // wires driven by part outputs or chip inputs
+}
+
+// wire returns a unique wire name based on name.
+//
+func (f *flattener) wire(name string) string {
+	n, idx := name, ""
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		n, idx = name[:i], name[i:]
+	}
+	w := name
+	for i := 1; f.used[w]; i++ {
+		w = n + "_" + strconv.Itoa(i) + idx
+	}
+	f.used[w] = true
+	return w
+}
+
+// expand adds to f the primitive parts of p, whose pins are connected to the
+// wires listed in m. prefix is the prefix of the wires internal to p.
+//
+func (f *flattener) expand(p *PartSpec, m map[string][]string, prefix string) error {
+	switch {
+	case f.prims[p]:
+		f.parts = append(f.parts, flatPart{p, m})
+		for _, k := range p.Inputs {
+			if ws := m[k]; len(ws) > 0 {
+				f.read[ws[0]] = true
+			}
+		}
+		for _, k := range p.Outputs {
+			for _, w := range m[k] {
+				f.drv[w] = true
+			}
+		}
+		return nil
+	case p.chip != nil:
+		return f.expandChip(p.chip, m, prefix)
+	}
+	impl := p.Impl
+	if n, _ := f.partSize(p); n != noImpl {
+		impl = f.best[p]
+	}
+	if impl == nil {
+		return errors.Errorf("part %s is not a primitive and has no gate-level implementation", p.Name)
+	}
+	if f.impl[p] {
+		return errors.Errorf("recursive gate-level implementation of part %s", p.Name)
+	}
+	f.impl[p] = true
+	defer delete(f.impl, p)
+	return f.expand(impl("").PartSpec, m, prefix)
+}
+
+// noImpl is the size of parts that cannot be built from the primitive parts.
+//
+const noImpl = int(^uint(0) >> 1)
+
+// partSize returns the number of primitive parts in the flattened version of
+// p, or noImpl if p cannot be built from the primitive parts. The smallest
+// implementation of p is recorded in f.best.
+//
+// Implementations that lead back to a part being sized are skipped. cut is
+// true if this happened below p, in which case the result only holds in the
+// context of the current search and is not cached.
+//
+func (f *flattener) partSize(p *PartSpec) (n int, cut bool) {
+	if f.prims[p] {
+		return 1, false
+	}
+	if n, ok := f.size[p]; ok {
+		return n, false
+	}
+	if f.visit[p] {
+		return noImpl, true
+	}
+	f.visit[p] = true
+	defer delete(f.visit, p)
+
+	n = noImpl
+	if p.chip != nil {
+		n = 0
+		for _, sp := range p.chip.parts {
+			sn, c := f.partSize(sp)
+			cut = cut || c
+			if sn == noImpl {
+				n = noImpl
+				break
+			}
+			n += sn
+		}
+	} else {
+		for _, impl := range append([]NewPartFn{p.Impl}, p.AltImpls...) {
+			if impl == nil {
+				continue
+			}
+			sn, c := f.partSize(impl("").PartSpec)
+			cut = cut || c
+			if sn < n {
+				n = sn
+				f.best[p] = impl
+			}
+		}
+	}
+	if !cut {
+		f.size[p] = n
+	}
+	return n, cut
+}
+
+func (f *flattener) expandChip(c *chip, m map[string][]string, prefix string) error {
+	// chip wire name -> flat wire names
+	names := make(map[string][]string)
+	for _, n := range cstPinNames {
+		names[n] = []string{n}
+	}
+	for _, k := range c.Inputs {
+		if w := c.Pinout[k]; w != "" {
+			if ws := m[k]; len(ws) > 0 {
+				names[w] = ws[:1]
+			} else {
+				names[w] = []string{False}
+			}
+		}
+	}
+	for _, k := range c.Outputs {
+		if w := c.Pinout[k]; w != "" && len(m[k]) > 0 {
+			names[w] = append(names[w], m[k]...)
+		}
+	}
+	for i, sp := range c.parts {
+		inst := pins.Ident(c.names[i])
+		sm := make(map[string][]string)
+		for _, pins := range [][]string{sp.Inputs, sp.Outputs} {
+			for _, k := range pins {
+				w := c.w.wireName(pin{i, k})
+				if w == "" {
+					continue
+				}
+				ws, ok := names[w]
+				if !ok {
+					n := w
+					if strings.HasPrefix(n, "__") {
+						// temporary wire name, name it after the part's pin
+						n = inst + "_" + k
+					}
+					ws = []string{f.wire(prefix + n)}
+					names[w] = ws
+				}
+				sm[k] = ws
+			}
+		}
+		if err := f.expand(sp, sm, prefix+inst+"_"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package hwsim_test
+
+import (
+	"testing"
+
+	"github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+	"github.com/db47h/hwsim/hwtest"
+)
+
+func TestFlatten(t *testing.T) {
+	nand := tl.nand("").PartSpec
+	flat, err := hwsim.Flatten(tl.cla4, tl.nand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ci := hwsim.Inspect(flat)
+	for _, p := range ci.Parts {
+		if p.Spec != nand {
+			t.Fatalf("part %s in flattened chip", p.Name)
+		}
+	}
+	if ci.Name != "CLA4" || len(ci.Inputs) != 9 || len(ci.Outputs) != 6 {
+		t.Fatalf("bad interface for flattened chip: %s %v %v", ci.Name, ci.Inputs, ci.Outputs)
+	}
+	var count func(p hwsim.NewPartFn) int
+	count = func(p hwsim.NewPartFn) int {
+		ci := hwsim.Inspect(p)
+		if ci == nil {
+			return 1
+		}
+		n := 0
+		for _, sp := range ci.Parts {
+			n += count(sp.Spec.NewPart)
+		}
+		return n
+	}
+	if n, exp := len(ci.Parts), count(tl.cla4); n != exp {
+		t.Errorf("got %d NAND gates, expected %d", n, exp)
+	}
+	hwtest.ComparePart(t, tl.cla4, flat)
+
+	// wires are named after the original ones
+	if ws := ci.Parts[0].Pins; ws["a"] != "a[0]" {
+		t.Errorf("got wires %v for the first part", ws)
+	}
+
+	if _, err = hwsim.Flatten(tl.cla4, tl.not); err == nil {
+		t.Error("no error when flattening with unusable primitives")
+	}
+}
+
+func TestFlatten_hwlib(t *testing.T) {
+	reg, err := hwsim.Chip("Reg4", "in[4], load", "out[4]",
+		hl.MuxN(4)("a=q, b=in, sel=load, out=d"),
+		hl.DFFN(4)("in=d, out=q, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := []struct {
+		part  hwsim.NewPartFn
+		prims []hwsim.NewPartFn
+	}{
+		{hl.MuxMWayN(4, 4), []hwsim.NewPartFn{hl.Nand}},
+		{hl.DMuxNWay(8), []hwsim.NewPartFn{hl.Nand}},
+		{hl.OrNWay(8), []hwsim.NewPartFn{hl.Nand}},
+		{hl.Xnor, []hwsim.NewPartFn{hl.Nand}},
+		{reg, []hwsim.NewPartFn{hl.Nand, hl.DFF}},
+		{reg, []hwsim.NewPartFn{hl.Nand, hl.Not, hl.DFF}},
+		{reg, []hwsim.NewPartFn{hl.And, hl.Or, hl.Not, hl.DFF}},
+		{hl.MuxMWayN(5, 2), []hwsim.NewPartFn{hl.And, hl.Or, hl.Not}},
+		{hl.DMuxNWay(3), []hwsim.NewPartFn{hl.And, hl.Not}},
+		{hl.DMuxMWayN(6, 2), []hwsim.NewPartFn{hl.Nand}},
+		{hl.Xor, []hwsim.NewPartFn{hl.And, hl.Or, hl.Not}},
+		{hl.Nand, []hwsim.NewPartFn{hl.Or, hl.Not}},
+		{hl.GateN("IMPLY", 2, func(a, b bool) bool { return !a || b }), []hwsim.NewPartFn{hl.And, hl.Or, hl.Not}},
+	}
+	for _, d := range td {
+		ok := make(map[*hwsim.PartSpec]bool)
+		for _, p := range d.prims {
+			ok[p("").PartSpec] = true
+		}
+		t.Run(d.part("").Name, func(t *testing.T) {
+			flat, err := hwsim.Flatten(d.part, d.prims...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range hwsim.Inspect(flat).Parts {
+				if !ok[p.Spec] {
+					t.Fatalf("part %s in flattened chip", p.Name)
+				}
+			}
+			hwtest.CompareSequential(t, d.part, flat, nil)
+		})
+	}
+
+	if _, err = hwsim.Flatten(reg, hl.Nand); err == nil {
+		t.Error("no error for DFF without gate-level implementation")
+	}
+	// NAND cannot be built from NOT gates alone.
+	if _, err = hwsim.Flatten(hl.Xor, hl.Not); err == nil {
+		t.Error("no error for NAND without gate-level implementation")
+	}
+}
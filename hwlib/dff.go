@@ -21,10 +21,16 @@ func DFF(c string) hwsim.Part {
 	return dffSpec.NewPart(c)
 }
 
+// dffGates is the size of a DFF in NAND gates, as in a classic positive
+// edge triggered D flip flop.
+//
+const dffGates = 6
+
 var dffSpec = &hwsim.PartSpec{
 	Name:    "DFF",
 	Inputs:  []string{pIn},
 	Outputs: []string{pOut},
+	Gates:   dffGates,
 	Mount: func(s *hwsim.Socket) hwsim.Updater {
 		return &dff{in: s.Wire(pIn), out: s.Wire(pOut)}
 	}}
@@ -48,6 +54,10 @@ func (d *dff) PostUpdate(clk bool) {
 	}
 }
 
+func (d *dff) State() interface{} { return d.v }
+
+func (d *dff) SetState(s interface{}) { d.v = s.(bool) }
+
 // DFFN creates a N bits DFF.
 //
 func DFFN(bits int) hwsim.NewPartFn {
@@ -56,6 +66,8 @@ func DFFN(bits int) hwsim.NewPartFn {
 		Name:    "DFF" + bs,
 		Inputs:  bus(bits, pIn),
 		Outputs: bus(bits, pOut),
+		Gates:   dffGates * bits,
+		Impl:    bitwiseImpl("DFF"+bs, busIO(bits, pIn), busIO(bits, pOut), bits, DFF, "in", "in[%d]", "out", "out[%d]"),
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			return &dffN{
 				in:  s.Bus(pIn, bits),
@@ -87,3 +99,71 @@ func (d *dffN) PostUpdate(clk bool) {
 		}
 	}
 }
+
+func (d *dffN) State() interface{} { return append([]bool(nil), d.v...) }
+
+func (d *dffN) SetState(s interface{}) { copy(d.v, s.([]bool)) }
+
+// DFFClk returns a data flip flop clocked by its clock input pin instead of
+// the main circuit clock. The clock pin is usually connected to a named clock
+// created with hwsim.Clock.
+//
+// The input is sampled on falling edges of the clock, i.e. during a half cycle
+// where clock is false after it was true during the previous half cycle. Like
+// DFF, the new value is output starting from the next half cycle. A DFFClk
+// with its clock pin connected to the clk constant input behaves like a DFF.
+//
+//	Inputs: in, clock
+//	Outputs: out
+//
+func DFFClk(c string) hwsim.Part {
+	return dffClkSpec.NewPart(c)
+}
+
+const pClock = "clock"
+
+var dffClkSpec = &hwsim.PartSpec{
+	Name:    "DFFClk",
+	Inputs:  []string{pIn, pClock},
+	Outputs: []string{pOut},
+	Gates:   dffGates,
+	Mount: func(s *hwsim.Socket) hwsim.Updater {
+		return &dffClk{
+			in:    s.Wire(pIn),
+			clock: s.Wire(pClock),
+			out:   s.Wire(pOut),
+			s:     dffClkState{t: true, cur: true},
+		}
+	}}
+
+type dffClkState struct {
+	t         bool // clk argument of the last half cycle
+	prev, cur bool // clock values during the previous and current half cycle
+	v         bool
+}
+
+type dffClk struct {
+	in, clock, out *hwsim.Wire
+	s              dffClkState
+}
+
+func (d *dffClk) Update(clk bool) {
+	d.out.Send(clk, d.s.v)
+}
+
+func (d *dffClk) PostUpdate(clk bool) {
+	v, c := d.in.Recv(clk), d.clock.Recv(clk)
+	if clk != d.s.t {
+		// new half cycle, as opposed to Circuit.Eval
+		d.s.t = clk
+		d.s.prev = d.s.cur
+	}
+	d.s.cur = c
+	if !c && d.s.prev {
+		d.s.v = v
+	}
+}
+
+func (d *dffClk) State() interface{} { return d.s }
+
+func (d *dffClk) SetState(s interface{}) { d.s = s.(dffClkState) }
@@ -95,3 +95,37 @@ func Test_bit_register(t *testing.T) {
 		}
 	}
 }
+
+func TestDFFClk(t *testing.T) {
+	dff, err := hw.Chip("DFF", "in", "out",
+		hl.DFFClk("in=in, clock=clk, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareSequential(t, hl.DFF, dff, nil)
+
+	// toggle flip flop in a clock domain 3 times slower than the main clock
+	var out, clk bool
+	c, err := hw.NewCircuit(
+		hw.Clock(3)("out=slowClk"),
+		hl.Not("in=q, out=d"),
+		hl.DFFClk("in=d, clock=slowClk, out=q"),
+		hw.Output(func(v bool) { out = v })("in=q"),
+		hw.Output(func(v bool) { clk = v })("in=slowClk"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 24; i++ {
+		c.Tick()
+		if exp := (2*i/3)%2 == 1; clk != exp {
+			t.Fatalf("cycle %d: slowClk = %v after tick, expected %v", i, clk, exp)
+		}
+		c.Tock()
+		// q toggles during the tock following each falling edge of slowClk
+		if exp := (i/3)%2 == 0; out != exp {
+			t.Fatalf("cycle %d: out = %v after tock, expected %v", i, out, exp)
+		}
+	}
+}
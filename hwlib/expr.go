@@ -0,0 +1,258 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwlib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/db47h/hwsim"
+	"github.com/pkg/errors"
+)
+
+// FromExpr returns a part that computes the given boolean expressions, built
+// like FromTruthTable.
+//
+// expr is a list of assignments to the outputs of the part, separated by
+// semicolons or new lines:
+//
+//	mux, err := hwlib.FromExpr("MUX", "out = a & ~sel | b & sel")
+//	ha, err := hwlib.FromExpr("HA", "sum = a ^ b; carry = a & b")
+//
+// The operators are, by decreasing precedence: ~ or ! (not), & (and), ^ (xor)
+// and | (or). Parentheses can be used for grouping, and 0, 1, false and true
+// are constants. Identifiers that are not outputs are the inputs of the part,
+// in order of appearance. An output can be used in the expressions of the
+// outputs assigned after it, but not in its own expression. The reserved
+// names false, true and clk cannot be used as outputs.
+//
+func FromExpr(name, expr string) (hwsim.NewPartFn, error) {
+	ins, outs, table, err := exprTable(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return synth(name, ins, outs, table)
+}
+
+// ExprLUT is a fast version of FromExpr that returns a lookup table part, like
+// TruthTableLUT.
+//
+func ExprLUT(name, expr string) (hwsim.NewPartFn, error) {
+	ins, outs, table, err := exprTable(expr)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return TruthTableLUT(name, strings.Join(ins, ", "), strings.Join(outs, ", "), table)
+}
+
+// exprTable parses expr and returns the truth table of the expressions.
+//
+func exprTable(expr string) (ins, outs []string, table []uint64, err error) {
+	p := &exprParser{in: expr, vars: make(map[string]int), outs: make(map[string]exprFn)}
+	fns, err := p.parse()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(p.ins) > maxSynthInputs {
+		return nil, nil, nil, errors.Errorf("%d inputs, at most %d supported", len(p.ins), maxSynthInputs)
+	}
+	table = make([]uint64, 1<<uint(len(p.ins)))
+	for i := range table {
+		for k, f := range fns {
+			if f(uint(i)) {
+				table[i] |= 1 << uint(k)
+			}
+		}
+	}
+	return p.ins, p.outNames, table, nil
+}
+
+// An exprFn returns the value of an expression for the given input values:
+// bit i of in is the value of input i.
+//
+type exprFn func(in uint) bool
+
+type exprParser struct {
+	in       string
+	pos      int
+	tok      string // current token, "" at end of input
+	tpos     int    // position of tok
+	ins      []string
+	vars     map[string]int    // input indices
+	outNames []string          // outputs
+	outs     map[string]exprFn // assigned outputs
+	cur      string            // output being assigned
+}
+
+func (p *exprParser) errorf(pos int, format string, args ...interface{}) error {
+	return errors.Errorf("in %s at pos %d: %s", strconv.Quote(p.in), pos+1, fmt.Sprintf(format, args...))
+}
+
+// next reads the next token. New lines are returned as ";".
+//
+func (p *exprParser) next() {
+	for p.pos < len(p.in) && (p.in[p.pos] == ' ' || p.in[p.pos] == '\t' || p.in[p.pos] == '\r') {
+		p.pos++
+	}
+	p.tpos = p.pos
+	if p.pos >= len(p.in) {
+		p.tok = ""
+		return
+	}
+	r, n := utf8.DecodeRuneInString(p.in[p.pos:])
+	if !isIdentRune(r) {
+		p.pos += n
+		p.tok = string(r)
+		if r == '\n' {
+			p.tok = ";"
+		}
+		return
+	}
+	for p.pos < len(p.in) {
+		r, n := utf8.DecodeRuneInString(p.in[p.pos:])
+		if !isIdentRune(r) {
+			break
+		}
+		p.pos += n
+	}
+	p.tok = p.in[p.tpos:p.pos]
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || r == '_' || unicode.IsDigit(r)
+}
+
+func (p *exprParser) parse() ([]exprFn, error) {
+	var fns []exprFn
+	p.next()
+	for {
+		for p.tok == ";" {
+			p.next()
+		}
+		if p.tok == "" {
+			break
+		}
+		pos, out := p.tpos, p.tok
+		if !isIdent(out) {
+			return nil, p.errorf(pos, "expected output name, got %q", out)
+		}
+		if out == hwsim.True || out == hwsim.False || out == hwsim.Clk {
+			return nil, p.errorf(pos, "reserved name %s used as output", out)
+		}
+		if _, ok := p.outs[out]; ok {
+			return nil, p.errorf(pos, "output %s assigned twice", out)
+		}
+		if _, ok := p.vars[out]; ok {
+			return nil, p.errorf(pos, "output %s already used as an input", out)
+		}
+		if p.next(); p.tok != "=" {
+			return nil, p.errorf(p.tpos, "expected =, got %q", p.tok)
+		}
+		p.next()
+		p.cur = out
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ";" && p.tok != "" {
+			return nil, p.errorf(p.tpos, "unexpected %q", p.tok)
+		}
+		p.outs[out] = f
+		p.outNames = append(p.outNames, out)
+		fns = append(fns, f)
+	}
+	if len(fns) == 0 {
+		return nil, errors.New("no outputs")
+	}
+	return fns, nil
+}
+
+func isIdent(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return s != "" && (unicode.IsLetter(r) || r == '_')
+}
+
+// binary parses a left associative binary operation.
+//
+func (p *exprParser) binary(op string, operand func() (exprFn, error), f func(a, b bool) bool) (exprFn, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == op {
+		p.next()
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		a := x
+		x = func(in uint) bool { return f(a(in), y(in)) }
+	}
+	return x, nil
+}
+
+func (p *exprParser) or() (exprFn, error) {
+	return p.binary("|", p.xor, func(a, b bool) bool { return a || b })
+}
+
+func (p *exprParser) xor() (exprFn, error) {
+	return p.binary("^", p.and, func(a, b bool) bool { return a != b })
+}
+
+func (p *exprParser) and() (exprFn, error) {
+	return p.binary("&", p.unary, func(a, b bool) bool { return a && b })
+}
+
+func (p *exprParser) unary() (exprFn, error) {
+	pos, tok := p.tpos, p.tok
+	switch {
+	case tok == "~" || tok == "!":
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(in uint) bool { return !x(in) }, nil
+	case tok == "(":
+		p.next()
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, p.errorf(p.tpos, "expected ), got %q", p.tok)
+		}
+		p.next()
+		return x, nil
+	case tok == "0" || tok == hwsim.False:
+		p.next()
+		return func(uint) bool { return false }, nil
+	case tok == "1" || tok == hwsim.True:
+		p.next()
+		return func(uint) bool { return true }, nil
+	case isIdent(tok):
+		if tok == p.cur {
+			return nil, p.errorf(pos, "output %s used in its own expression", tok)
+		}
+		p.next()
+		if f, ok := p.outs[tok]; ok {
+			return f, nil
+		}
+		i, ok := p.vars[tok]
+		if !ok {
+			i = len(p.ins)
+			p.vars[tok] = i
+			p.ins = append(p.ins, tok)
+		}
+		bit := uint(1) << uint(i)
+		return func(in uint) bool { return in&bit != 0 }, nil
+	case tok == "" || tok == ";":
+		return nil, p.errorf(pos, "unexpected end of expression")
+	default:
+		return nil, p.errorf(pos, "unexpected %q", tok)
+	}
+}
@@ -35,11 +35,13 @@ func bus(bits int, names ...string) []string {
 	return b
 }
 
-var notGate = hwsim.PartSpec{Name: "NOR", Inputs: []string{pIn}, Outputs: []string{pOut},
+var notGate = hwsim.PartSpec{Name: "NOT", Inputs: []string{pIn}, Outputs: []string{pOut}, Gates: 1,
 	Mount: func(s *hwsim.Socket) hwsim.Updater {
 		in, out := s.Wire(pIn), s.Wire(pOut)
 		return hwsim.UpdaterFn(func(clk bool) { out.Send(clk, !in.Recv(clk)) })
-	}}
+	},
+	Bitwise: func(in, out []uint64) { out[0] = ^in[0] },
+}
 
 // Not returns a NOT gate.
 //
@@ -59,12 +61,60 @@ func (g gate) mount(s *hwsim.Socket) hwsim.Updater {
 	return hwsim.UpdaterFn(func(clk bool) { out.Send(clk, g(a.Recv(clk), b.Recv(clk))) })
 }
 
+// bitwise returns the bitwise equivalent of fn.
+//
+func (g gate) bitwise() func(a, b uint64) uint64 {
+	// m[i] is all 1s if g(i&1 != 0, i&2 != 0)
+	var m [4]uint64
+	for i := range m {
+		if g(i&1 != 0, i&2 != 0) {
+			m[i] = ^uint64(0)
+		}
+	}
+	return func(a, b uint64) uint64 {
+		return m[0]&^a&^b | m[1]&a&^b | m[2]&^a&b | m[3]&a&b
+	}
+}
+
+// nandCount returns the number of NAND gates required to implement g.
+//
+func (g gate) nandCount() int {
+	// truth table of g, bit i set if g(i&1 != 0, i&2 != 0)
+	var t uint
+	for i := uint(0); i < 4; i++ {
+		if g(i&1 != 0, i&2 != 0) {
+			t |= 1 << i
+		}
+	}
+	return nandCounts[t]
+}
+
+// nandCounts is the number of NAND gates required to implement any two
+// inputs gate, indexed by truth table.
+//
+var nandCounts = [16]int{
+	0x0: 0, 0xF: 0, // constants
+	0xA: 0, 0xC: 0, // a, b
+	0x5: 1, 0x3: 1, // !a, !b
+	0x7: 1,         // NAND
+	0x8: 2,         // AND
+	0xB: 2, 0xD: 2, // a || !b, !a || b
+	0xE: 3,         // OR
+	0x2: 3, 0x4: 3, // a && !b, !a && b
+	0x1: 4, // NOR
+	0x6: 4, // XOR
+	0x9: 5, // XNOR
+}
+
 func newGate(name string, fn func(a, b bool) bool) *hwsim.PartSpec {
+	f := gate(fn).bitwise()
 	return &hwsim.PartSpec{
 		Name:    name,
 		Inputs:  gateIn,
 		Outputs: gateOut,
+		Gates:   gate(fn).nandCount(),
 		Mount:   gate(fn).mount,
+		Bitwise: func(in, out []uint64) { out[0] = f(in[0], in[1]) },
 	}
 }
 
@@ -129,10 +179,12 @@ func Xor(w string) hwsim.Part { return xor.NewPart(w) }
 func Xnor(w string) hwsim.Part { return xnor.NewPart(w) }
 
 func notN(bits int) *hwsim.PartSpec {
+	name := "NOT" + strconv.Itoa(bits)
 	return &hwsim.PartSpec{
-		Name:    "NOT" + strconv.Itoa(bits),
+		Name:    name,
 		Inputs:  bus(bits, pIn),
 		Outputs: bus(bits, pOut),
+		Impl:    bitwiseImpl(name, busIO(bits, pIn), busIO(bits, pOut), bits, Not, "in", "in[%d]", "out", "out[%d]"),
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			ins := s.Bus(pIn, bits)
 			outs := s.Bus(pOut, bits)
@@ -142,7 +194,13 @@ func notN(bits int) *hwsim.PartSpec {
 						outs[i].Send(clk, !pin.Recv(clk))
 					}
 				})
-		}}
+		},
+		Bitwise: func(in, out []uint64) {
+			for i, v := range in {
+				out[i] = ^v
+			}
+		},
+	}
 }
 
 // NotN returns a N-bits NOT gate.
@@ -152,7 +210,7 @@ func notN(bits int) *hwsim.PartSpec {
 //	Function: for i := range out { out[i] = !in[i] }
 //
 func NotN(bits int) hwsim.NewPartFn {
-	return notN(16).NewPart
+	return notN(bits).NewPart
 }
 
 var (
@@ -174,12 +232,23 @@ func (g *gateN) mount(s *hwsim.Socket) hwsim.Updater {
 		})
 }
 
-func newGateN(name string, bits int, f func(bool, bool) bool) *hwsim.PartSpec {
+// newGateN returns a N-bits version of the 1 bit gate g, whose function is f.
+//
+func newGateN(g *hwsim.PartSpec, bits int, f func(bool, bool) bool) *hwsim.PartSpec {
+	name := g.Name
+	bf := gate(f).bitwise()
 	return &hwsim.PartSpec{
 		Name:    name + strconv.Itoa(bits),
 		Inputs:  bus(bits, pA, pB),
 		Outputs: bus(bits, pOut),
 		Mount:   (&gateN{bits, f}).mount,
+		Bitwise: func(in, out []uint64) {
+			for i := range out {
+				out[i] = bf(in[i], in[bits+i])
+			}
+		},
+		Impl: bitwiseImpl(name+strconv.Itoa(bits), busIO(bits, pA, pB), busIO(bits, pOut), bits,
+			g.NewPart, "a", "a[%d]", "b", "b[%d]", "out", "out[%d]"),
 	}
 }
 
@@ -190,7 +259,10 @@ func newGateN(name string, bits int, f func(bool, bool) bool) *hwsim.PartSpec {
 //	Function: for i := range out { out[i] = f(a[i], b[i]) }
 //
 func GateN(name string, bits int, f func(bool, bool) bool) hwsim.NewPartFn {
-	return newGateN(name, bits, f).NewPart
+	g := newGate(name, f)
+	g.Impl = gateImpl(g)
+	g.AltImpls = []hwsim.NewPartFn{sopImpl(g)}
+	return newGateN(g, bits, f).NewPart
 }
 
 // AndN returns a N-bits AND gate.
@@ -200,7 +272,7 @@ func GateN(name string, bits int, f func(bool, bool) bool) hwsim.NewPartFn {
 //	Function: for i := range out { out[i] = a[i] && b[i] }
 //
 func AndN(bits int) hwsim.NewPartFn {
-	return newGateN("AND", bits, func(a, b bool) bool { return a && b }).NewPart
+	return newGateN(and, bits, func(a, b bool) bool { return a && b }).NewPart
 }
 
 // NandN returns a N-bits NAND gate.
@@ -210,7 +282,7 @@ func AndN(bits int) hwsim.NewPartFn {
 //	Function: for i := range out { out[i] = !(a[i] && b[i]) }
 //
 func NandN(bits int) hwsim.NewPartFn {
-	return newGateN("NAND", bits, func(a, b bool) bool { return !(a && b) }).NewPart
+	return newGateN(nand, bits, func(a, b bool) bool { return !(a && b) }).NewPart
 }
 
 // OrN returns a N-bits OR gate.
@@ -220,7 +292,7 @@ func NandN(bits int) hwsim.NewPartFn {
 //	Function: for i := range out { out[i] = (a[i] || b[i]) }
 //
 func OrN(bits int) hwsim.NewPartFn {
-	return newGateN("OR", bits, func(a, b bool) bool { return a || b }).NewPart
+	return newGateN(or, bits, func(a, b bool) bool { return a || b }).NewPart
 }
 
 // NorN returns a N-bits NOR gate.
@@ -230,7 +302,7 @@ func OrN(bits int) hwsim.NewPartFn {
 //	Function: for i := range out { out[i] = !(a[i] || b[i]) }
 //
 func NorN(bits int) hwsim.NewPartFn {
-	return newGateN("NOR", bits, func(a, b bool) bool { return !(a || b) }).NewPart
+	return newGateN(nor, bits, func(a, b bool) bool { return !(a || b) }).NewPart
 }
 
 // OrNWay returns a N-Way OR gate.
@@ -244,6 +316,14 @@ func OrNWay(ways int) hwsim.NewPartFn {
 		Name:    "OR" + strconv.Itoa(ways) + "Way",
 		Inputs:  bus(ways, pIn),
 		Outputs: hwsim.IO(pOut),
+		Impl:    nWayImpl("OR"+strconv.Itoa(ways)+"Way", ways, Or),
+		Bitwise: func(in, out []uint64) {
+			var v uint64
+			for _, i := range in {
+				v |= i
+			}
+			out[0] = v
+		},
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			in := s.Bus(pIn, ways)
 			out := s.Wire(pOut)
@@ -260,6 +340,28 @@ func OrNWay(ways int) hwsim.NewPartFn {
 		}}).NewPart
 }
 
+// nWayImpl returns the implementation of a N-Way gate as a chain of 2 inputs
+// gates.
+//
+func nWayImpl(name string, ways int, gate hwsim.NewPartFn) hwsim.NewPartFn {
+	return impl(name, busIO(ways, pIn), pOut, func() []hwsim.Part {
+		if ways == 1 {
+			return []hwsim.Part{gate("a=in[0], b=in[0], out=out")}
+		}
+		parts := make([]hwsim.Part, ways-1)
+		prev := "in[0]"
+		for i := range parts {
+			out := "w" + strconv.Itoa(i)
+			if i == len(parts)-1 {
+				out = pOut
+			}
+			parts[i] = gate("a=" + prev + ", b=in[" + strconv.Itoa(i+1) + "], out=" + out)
+			prev = out
+		}
+		return parts
+	})
+}
+
 // AndNWay returns a N-Way OR gate.
 //
 //	Inputs: in[n]
@@ -271,6 +373,14 @@ func AndNWay(ways int) hwsim.NewPartFn {
 		Name:    "AND" + strconv.Itoa(ways) + "Way",
 		Inputs:  bus(ways, pIn),
 		Outputs: hwsim.IO(pOut),
+		Impl:    nWayImpl("AND"+strconv.Itoa(ways)+"Way", ways, And),
+		Bitwise: func(in, out []uint64) {
+			v := ^uint64(0)
+			for _, i := range in {
+				v &= i
+			}
+			out[0] = v
+		},
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			in := s.Bus(pIn, ways)
 			out := s.Wire(pOut)
@@ -1,6 +1,7 @@
 package hwlib_test
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 	"testing/quick"
@@ -16,6 +17,9 @@ const testTPC = 8
 func testGate(t *testing.T, name string, gate hw.NewPartFn, result [][]bool) {
 	t.Helper()
 	part := gate("").PartSpec // build dummy gate just to get to the partspec
+	if part.Name != name {
+		t.Errorf("expected part name %s, got %s", name, part.Name)
+	}
 	inputs := make([]bool, len(part.Inputs))
 	outputs := make([]bool, len(part.Outputs))
 	var w strings.Builder
@@ -136,6 +140,15 @@ func Test_gateN_builtin(t *testing.T) {
 			}
 		})
 	}
+
+	for _, bits := range []int{1, 8, 16, 64} {
+		for _, gate := range []hw.NewPartFn{hl.AndN(bits), hl.NandN(bits), hl.OrN(bits), hl.NorN(bits), hl.NotN(bits)} {
+			p := gate("")
+			if len(p.Outputs) != bits || len(p.Inputs) != bits && len(p.Inputs) != 2*bits {
+				t.Errorf("%s(%d): got %d inputs, %d outputs", p.Name, bits, len(p.Inputs), len(p.Outputs))
+			}
+		}
+	}
 }
 
 func TestOrNWays(t *testing.T) {
@@ -161,3 +174,91 @@ func TestAndNWays(t *testing.T) {
 	}
 	hwtest.ComparePart(t, hl.AndNWay(4), and4)
 }
+
+func TestImpl(t *testing.T) {
+	td := []hw.NewPartFn{
+		hl.Not, hl.And, hl.Nand, hl.Or, hl.Nor, hl.Xor, hl.Xnor, hl.Mux, hl.DMux,
+		hl.GateN("IMPLY", 2, func(a, b bool) bool { return !a || b }),
+		hl.DMuxNWay(4),
+		hl.NotN(16),
+		hl.AndN(16),
+		hl.NandN(8),
+		hl.OrN(16),
+		hl.NorN(4),
+		hl.OrNWay(8),
+		hl.AndNWay(16),
+		hl.MuxN(16),
+		hl.DMuxN(16),
+		hl.MuxMWayN(4, 16),
+		hl.MuxMWayN(8, 4),
+		hl.DMuxMWayN(4, 16),
+		hl.DMuxMWayN(8, 4),
+	}
+	for _, p := range td {
+		spec := p("").PartSpec
+		t.Run(spec.Name, func(t *testing.T) {
+			if spec.Impl != nil {
+				hwtest.ComparePart(t, p, spec.Impl)
+			}
+			for _, alt := range spec.AltImpls {
+				hwtest.ComparePart(t, p, alt)
+			}
+		})
+	}
+}
+
+// TestGateImpl checks the gate-level implementations of all two inputs
+// gates.
+//
+func TestGateImpl(t *testing.T) {
+	for tt := uint(0); tt < 16; tt++ {
+		tt := tt
+		f := func(a, b bool) bool {
+			i := uint(0)
+			if a {
+				i |= 1
+			}
+			if b {
+				i |= 2
+			}
+			return tt&(1<<i) != 0
+		}
+		// the implementation of GateN is made of 1 bit gates with the same function
+		g := hw.Inspect(hl.GateN("G", 1, f)("").Impl).Parts[0].Spec
+		t.Run(strconv.Itoa(int(tt)), func(t *testing.T) {
+			hwtest.ComparePart(t, g.NewPart, g.Impl)
+		})
+	}
+}
+
+func TestBitwise(t *testing.T) {
+	td := []hw.NewPartFn{
+		hl.Not, hl.And, hl.Nand, hl.Or, hl.Nor, hl.Xor, hl.Xnor, hl.Mux, hl.DMux,
+		hl.NotN(8),
+		hl.AndN(4),
+		hl.OrN(4),
+		hl.GateN("IMPL", 4, func(a, b bool) bool { return !a || b }),
+		hl.OrNWay(8),
+		hl.AndNWay(8),
+		hl.MuxN(4),
+		hl.DMuxN(4),
+		hl.MuxMWayN(4, 2),
+		hl.MuxMWayN(8, 2),
+		hl.DMuxNWay(4),
+		hl.DMuxNWay(8),
+		hl.DMuxMWayN(4, 2),
+		hl.DMuxMWayN(8, 2),
+	}
+	for _, p := range td {
+		spec := *p("").PartSpec
+		if spec.Bitwise == nil {
+			t.Errorf("%s: no Bitwise function", spec.Name)
+			continue
+		}
+		// compare with the regular implementation
+		spec.Bitwise = nil
+		t.Run(spec.Name, func(t *testing.T) {
+			hwtest.ComparePartWithOptions(t, p, spec.NewPart, &hwtest.Options{Batch: true})
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwlib
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/db47h/hwsim"
+)
+
+// impl returns a NewPartFn for a chip built from the parts returned by
+// parts. It is meant to be used as the gate-level implementation of custom
+// parts (see hwsim.PartSpec.Impl), and panics if the chip cannot be built.
+//
+func impl(name, inputs, outputs string, parts func() []hwsim.Part) hwsim.NewPartFn {
+	return func(c string) hwsim.Part {
+		chip, err := hwsim.Chip(name, inputs, outputs, parts()...)
+		if err != nil {
+			panic(err)
+		}
+		return chip(c)
+	}
+}
+
+// conns builds a connection string from pairs of pin and wire names. Each
+// %d in a pin or wire name is replaced with i.
+//
+func conns(i int, pairs ...string) string {
+	is := strconv.Itoa(i)
+	var b strings.Builder
+	for j := 0; j < len(pairs); j += 2 {
+		if j > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strings.Replace(pairs[j], "%d", is, -1))
+		b.WriteByte('=')
+		b.WriteString(strings.Replace(pairs[j+1], "%d", is, -1))
+	}
+	return b.String()
+}
+
+func busIO(bits int, names ...string) string {
+	bs := "[" + strconv.Itoa(bits) + "]"
+	return strings.Join(names, bs+", ") + bs
+}
+
+// bitwiseImpl returns the implementation of a N-bits part as bits
+// instances of a 1 bit part. pins lists pairs of pin names in the 1 bit part
+// and in the N-bits part, where %d is replaced with the bit number.
+//
+func bitwiseImpl(name, inputs, outputs string, bits int, part hwsim.NewPartFn, pins ...string) hwsim.NewPartFn {
+	return impl(name, inputs, outputs, func() []hwsim.Part {
+		parts := make([]hwsim.Part, bits)
+		for i := range parts {
+			parts[i] = part(conns(i, pins...))
+		}
+		return parts
+	})
+}
+
+// nandImpls are the implementations of two inputs gates with NAND gates,
+// indexed by truth table (see gate.nandCount).
+//
+var nandImpls = map[uint][]string{
+	0x0: {"a=true, b=true, out=out"},
+	0xF: {"a=false, b=false, out=out"},
+	0xA: {"a=a, b=a, out=na", "a=na, b=na, out=out"},
+	0xC: {"a=b, b=b, out=nb", "a=nb, b=nb, out=out"},
+	0x7: {"a=a, b=b, out=out"},
+	0x5: {"a=a, b=a, out=out"},
+	0x3: {"a=b, b=b, out=out"},
+	0x8: {"a=a, b=b, out=n", "a=n, b=n, out=out"},
+	0xB: {"a=a, b=a, out=na", "a=na, b=b, out=out"},
+	0xD: {"a=b, b=b, out=nb", "a=a, b=nb, out=out"},
+	0xE: {"a=a, b=a, out=na", "a=b, b=b, out=nb", "a=na, b=nb, out=out"},
+	0x2: {"a=b, b=b, out=nb", "a=a, b=nb, out=x", "a=x, b=x, out=out"},
+	0x4: {"a=a, b=a, out=na", "a=na, b=b, out=x", "a=x, b=x, out=out"},
+	0x1: {"a=a, b=a, out=na", "a=b, b=b, out=nb", "a=na, b=nb, out=o", "a=o, b=o, out=out"},
+	0x6: {"a=a, b=b, out=n", "a=a, b=n, out=x", "a=n, b=b, out=y", "a=x, b=y, out=out"},
+	0x9: {"a=a, b=b, out=n", "a=a, b=n, out=x", "a=n, b=b, out=y", "a=x, b=y, out=o", "a=o, b=o, out=out"},
+}
+
+// gateTable returns the truth table of a two inputs gate: bit i is the output
+// for a = i&1, b = i&2.
+//
+func gateTable(g *hwsim.PartSpec) uint {
+	// lane i of the inputs is a = i&1, b = i&2
+	out := make([]uint64, 1)
+	g.Bitwise([]uint64{0xA, 0xC}, out)
+	return uint(out[0] & 0xF)
+}
+
+// gateImpl returns the implementation of a two inputs gate with NAND gates.
+//
+func gateImpl(g *hwsim.PartSpec) hwsim.NewPartFn {
+	t := gateTable(g)
+	return impl(g.Name, "a, b", "out", func() []hwsim.Part {
+		var parts []hwsim.Part
+		for _, c := range nandImpls[t] {
+			parts = append(parts, Nand(c))
+		}
+		return parts
+	})
+}
+
+// sopImpl returns the implementation of a two inputs gate with AND, OR and NOT
+// gates, built by FromTruthTable on first use.
+//
+func sopImpl(g *hwsim.PartSpec) hwsim.NewPartFn {
+	t := gateTable(g)
+	table := []uint64{uint64(t & 1), uint64(t >> 1 & 1), uint64(t >> 2 & 1), uint64(t >> 3 & 1)}
+	var (
+		once sync.Once
+		fn   hwsim.NewPartFn
+		err  error
+	)
+	return func(c string) hwsim.Part {
+		once.Do(func() { fn, err = synth(g.Name, []string{pA, pB}, []string{pOut}, table) })
+		if err != nil {
+			panic(err)
+		}
+		return fn(c)
+	}
+}
+
+// nandAndImpl and nandOrImpl are the implementations of a NAND gate with AND
+// or OR gates, and NOT gates.
+//
+func nandAndImpl(c string) hwsim.Part {
+	return impl("NAND", "a, b", "out", func() []hwsim.Part {
+		return []hwsim.Part{
+			And("a=a, b=b, out=x"),
+			Not("in=x, out=out"),
+		}
+	})(c)
+}
+
+func nandOrImpl(c string) hwsim.Part {
+	return impl("NAND", "a, b", "out", func() []hwsim.Part {
+		return []hwsim.Part{
+			Not("in=a, out=na"),
+			Not("in=b, out=nb"),
+			Or("a=na, b=nb, out=out"),
+		}
+	})(c)
+}
+
+// muxImpl is the gate-level implementation of a Mux.
+//
+func muxImpl(c string) hwsim.Part {
+	return impl("MUX", "a, b, sel", "out", func() []hwsim.Part {
+		return []hwsim.Part{
+			Nand("a=sel, b=sel, out=nsel"),
+			Nand("a=a, b=nsel, out=x"),
+			Nand("a=b, b=sel, out=y"),
+			Nand("a=x, b=y, out=out"),
+		}
+	})(c)
+}
+
+// muxAndOrImpl is the implementation of a Mux with AND, OR and NOT gates.
+//
+func muxAndOrImpl(c string) hwsim.Part {
+	return impl("MUX", "a, b, sel", "out", func() []hwsim.Part {
+		return []hwsim.Part{
+			Not("in=sel, out=nsel"),
+			And("a=a, b=nsel, out=x"),
+			And("a=b, b=sel, out=y"),
+			Or("a=x, b=y, out=out"),
+		}
+	})(c)
+}
+
+// dmuxImpl is the gate-level implementation of a DMux.
+//
+func dmuxImpl(c string) hwsim.Part {
+	return impl("DMUX", "in, sel", "a, b", func() []hwsim.Part {
+		return []hwsim.Part{
+			Nand("a=sel, b=sel, out=nsel"),
+			Nand("a=in, b=nsel, out=x"),
+			Nand("a=x, b=x, out=a"),
+			Nand("a=in, b=sel, out=y"),
+			Nand("a=y, b=y, out=b"),
+		}
+	})(c)
+}
+
+// dmuxAndImpl is the implementation of a DMux with AND and NOT gates.
+//
+func dmuxAndImpl(c string) hwsim.Part {
+	return impl("DMUX", "in, sel", "a, b", func() []hwsim.Part {
+		return []hwsim.Part{
+			Not("in=sel, out=nsel"),
+			And("a=in, b=nsel, out=a"),
+			And("a=in, b=sel, out=b"),
+		}
+	})(c)
+}
+
+// notImpl is the gate-level implementation of a NOT gate.
+//
+func notImpl(c string) hwsim.Part {
+	return impl("NOT", "in", "out", func() []hwsim.Part {
+		return []hwsim.Part{Nand("a=in, b=in, out=out")}
+	})(c)
+}
+
+// The implementations of basic gates are set here in order to prevent
+// initialization loops. NAND is the base primitive: its implementations with
+// AND, OR and NOT gates are only alternatives for Flatten, so that tools that
+// follow Impl always end with NAND gates.
+//
+func init() {
+	notGate.Impl = notImpl
+	muxSpec.Impl = muxImpl
+	muxSpec.AltImpls = []hwsim.NewPartFn{muxAndOrImpl}
+	dmux.Impl = dmuxImpl
+	dmux.AltImpls = []hwsim.NewPartFn{dmuxAndImpl}
+	nand.AltImpls = []hwsim.NewPartFn{nandAndImpl, nandOrImpl}
+	for _, g := range []*hwsim.PartSpec{and, or} {
+		g.Impl = gateImpl(g)
+	}
+	for _, g := range []*hwsim.PartSpec{nor, xor, xnor} {
+		g.Impl = gateImpl(g)
+		g.AltImpls = []hwsim.NewPartFn{sopImpl(g)}
+	}
+}
@@ -6,6 +6,7 @@ package hwlib
 import (
 	bts "math/bits"
 	"strconv"
+	"strings"
 
 	"github.com/db47h/hwsim"
 )
@@ -34,9 +35,12 @@ var muxSpec = hwsim.PartSpec{
 	Name:    "MUX",
 	Inputs:  []string{pA, pB, pSel},
 	Outputs: []string{pOut},
+	Gates:   4,
 	Mount: func(s *hwsim.Socket) hwsim.Updater {
 		return &mux{s.Wire(pA), s.Wire(pB), s.Wire(pSel), s.Wire(pOut)}
-	}}
+	},
+	Bitwise: func(in, out []uint64) { out[0] = in[0]&^in[2] | in[1]&in[2] },
+}
 
 // DMux returns a demultiplexer.
 //
@@ -50,6 +54,7 @@ var dmux = hwsim.PartSpec{
 	Name:    "DMUX",
 	Inputs:  []string{pIn, pSel},
 	Outputs: []string{pA, pB},
+	Gates:   5,
 	Mount: func(s *hwsim.Socket) hwsim.Updater {
 		in, sel, a, b := s.Wire(pIn), s.Wire(pSel), s.Wire(pA), s.Wire(pB)
 		return hwsim.UpdaterFn(
@@ -63,6 +68,7 @@ var dmux = hwsim.PartSpec{
 				}
 			})
 	},
+	Bitwise: func(in, out []uint64) { out[0], out[1] = in[0]&^in[1], in[0]&in[1] },
 }
 
 type muxNinst struct {
@@ -85,13 +91,23 @@ func (m *muxNinst) Update(clk bool) {
 }
 
 func muxN(bits int) *hwsim.PartSpec {
+	name := "Mux" + strconv.Itoa(bits)
 	return &hwsim.PartSpec{
-		Name:    "Mux" + strconv.Itoa(bits),
+		Name:    name,
 		Inputs:  append(bus(bits, pA, pB), pSel),
 		Outputs: bus(bits, pOut),
+		Impl: bitwiseImpl(name, busIO(bits, pA, pB)+", sel", busIO(bits, pOut), bits, Mux,
+			"a", "a[%d]", "b", "b[%d]", "sel", "sel", "out", "out[%d]"),
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			return &muxNinst{s.Bus(pA, bits), s.Bus(pB, bits), s.Bus(pOut, bits), s.Wire(pSel)}
-		}}
+		},
+		Bitwise: func(in, out []uint64) {
+			sel := in[2*bits]
+			for i := range out {
+				out[i] = in[i]&^sel | in[bits+i]&sel
+			}
+		},
+	}
 }
 
 // MuxN returns a N-bits Mux
@@ -111,10 +127,13 @@ func MuxN(bits int) hwsim.NewPartFn {
 //	Function: if sel == 0 { a = in; b = 0 } else { a = 0; b = in }
 //
 func DMuxN(bits int) hwsim.NewPartFn {
+	name := "DMux" + strconv.Itoa(bits)
 	return (&hwsim.PartSpec{
-		Name:    "DMux" + strconv.Itoa(bits),
+		Name:    name,
 		Inputs:  append(bus(bits, pIn), pSel),
 		Outputs: bus(bits, pA, pB),
+		Impl: bitwiseImpl(name, busIO(bits, pIn)+", sel", busIO(bits, pA, pB), bits, DMux,
+			"in", "in[%d]", "sel", "sel", "a", "a[%d]", "b", "b[%d]"),
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			in, sel, a, b := s.Bus(pIn, bits), s.Wire(pSel), s.Bus(pA, bits), s.Bus(pB, bits)
 			return hwsim.UpdaterFn(
@@ -134,7 +153,29 @@ func DMuxN(bits int) hwsim.NewPartFn {
 						o.Send(clk, false)
 					}
 				})
-		}}).NewPart
+		},
+		Bitwise: func(in, out []uint64) {
+			sel := in[bits]
+			for i, v := range in[:bits] {
+				out[i], out[bits+i] = v&^sel, v&sel
+			}
+		},
+	}).NewPart
+}
+
+// selMask returns a mask of the lanes where the value of the selector bus
+// sel is v.
+//
+func selMask(sel []uint64, v int) uint64 {
+	m := ^uint64(0)
+	for i, s := range sel {
+		if v&(1<<uint(i)) != 0 {
+			m &= s
+		} else {
+			m &^= s
+		}
+	}
+	return m
 }
 
 var inputNames = [32]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z", "A", "B", "C", "D", "E", "F"}
@@ -162,12 +203,13 @@ func MuxMWayN(ways int, bits int) hwsim.NewPartFn {
 		inputs[ways*bits+w] = "sel[" + strconv.Itoa(w) + "]"
 	}
 
+	name := "Mux" + strconv.Itoa(ways) + "Way" + strconv.Itoa(bits)
 	p := &hwsim.PartSpec{
-		Name:    "Mux" + strconv.Itoa(ways) + "Way" + strconv.Itoa(bits),
+		Name:    name,
 		Inputs:  inputs,
 		Outputs: bus(bits, pOut),
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
-			in := make([]hwsim.Bus, 1<<uint(selBits))
+			in := make([]hwsim.Bus, ways)
 			for i := range in {
 				in[i] = s.Bus(inputNames[i], bits)
 			}
@@ -175,15 +217,74 @@ func MuxMWayN(ways int, bits int) hwsim.NewPartFn {
 			out := s.Bus(pOut, bits)
 			return hwsim.UpdaterFn(
 				func(clk bool) {
-					selIn := in[sel.Recv(clk)]
+					selV := int(sel.Recv(clk))
+					if selV >= ways {
+						// no such input
+						for _, o := range out {
+							o.Send(clk, false)
+						}
+						return
+					}
+					selIn := in[selV]
 					for i, o := range out {
 						o.Send(clk, selIn[i].Recv(clk))
 					}
 				})
-		}}
+		},
+		Bitwise: func(in, out []uint64) {
+			sel := in[ways*bits:]
+			for i := range out {
+				out[i] = 0
+			}
+			for w := 0; w < ways; w++ {
+				m := selMask(sel, w)
+				for i := range out {
+					out[i] |= in[w*bits+i] & m
+				}
+			}
+		},
+	}
+	if ways > 1 {
+		p.Impl = muxTreeImpl(name, ways, bits)
+	}
 	return p.NewPart
 }
 
+// muxTreeImpl returns the implementation of a M-Way N-bits Mux as a tree of
+// N-bits Muxes. If M is not a power of two, the missing inputs are false.
+//
+func muxTreeImpl(name string, ways, bits int) hwsim.NewPartFn {
+	selBits := bts.Len8(uint8(ways - 1))
+	ins := busIO(bits, inputNames[:ways]...) + ", " + busIO(selBits, pSel)
+	return impl(name, ins, busIO(bits, pOut), func() []hwsim.Part {
+		var parts []hwsim.Part
+		mux := MuxN(bits)
+		wires := make([]string, 1<<uint(selBits))
+		copy(wires, inputNames[:ways])
+		for i := ways; i < len(wires); i++ {
+			wires[i] = "0"
+		}
+		for level := 0; level < selBits; level++ {
+			next := make([]string, len(wires)/2)
+			for i := range next {
+				a, b := wires[2*i], wires[2*i+1]
+				if a == "0" && b == "0" {
+					next[i] = "0"
+					continue
+				}
+				next[i] = "l" + strconv.Itoa(level) + "_" + strconv.Itoa(i)
+				if len(next) == 1 {
+					next[i] = pOut
+				}
+				parts = append(parts, mux("a="+a+", b="+b+
+					", sel=sel["+strconv.Itoa(level)+"], out="+next[i]))
+			}
+			wires = next
+		}
+		return parts
+	})
+}
+
 // DMuxNWay returns a N-Way demuxer.
 //
 //	Inputs: in, sel[selBits]
@@ -203,7 +304,7 @@ func DMuxNWay(ways int) hwsim.NewPartFn {
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			in := s.Wire(pIn)
 			sel := s.Bus(pSel, selBits)
-			outs := make([]*hwsim.Wire, 1<<uint(selBits))
+			outs := make([]*hwsim.Wire, ways)
 			for i := range outs {
 				outs[i] = s.Wire(inputNames[i])
 			}
@@ -218,7 +319,16 @@ func DMuxNWay(ways int) hwsim.NewPartFn {
 						}
 					}
 				})
-		}}
+		},
+		Bitwise: func(in, out []uint64) {
+			for i := range out {
+				out[i] = in[0] & selMask(in[1:], i)
+			}
+		},
+	}
+	if ways > 1 {
+		p.Impl = dmuxTreeImpl(p.Name, ways, 0)
+	}
 	return p.NewPart
 }
 
@@ -241,14 +351,15 @@ func DMuxMWayN(ways int, bits int) hwsim.NewPartFn {
 		}
 	}
 
+	name := "DMux" + strconv.Itoa(ways) + "Way"
 	p := &hwsim.PartSpec{
-		Name:    "DMux" + strconv.Itoa(ways) + "Way",
+		Name:    name,
 		Inputs:  append(bus(bits, pIn), bus(selBits, pSel)...),
 		Outputs: outputs,
 		Mount: func(s *hwsim.Socket) hwsim.Updater {
 			in := s.Bus(pIn, bits)
 			sel := s.Bus(pSel, selBits)
-			outs := make([]hwsim.Bus, 1<<uint(selBits))
+			outs := make([]hwsim.Bus, ways)
 			for i := range outs {
 				outs[i] = s.Bus(inputNames[i], bits)
 			}
@@ -267,6 +378,65 @@ func DMuxMWayN(ways int, bits int) hwsim.NewPartFn {
 						}
 					}
 				})
-		}}
+		},
+		Bitwise: func(in, out []uint64) {
+			sel := in[bits:]
+			for w := 0; w < ways; w++ {
+				m := selMask(sel, w)
+				for i, v := range in[:bits] {
+					out[w*bits+i] = v & m
+				}
+			}
+		},
+	}
+	if ways > 1 {
+		p.Impl = dmuxTreeImpl(name, ways, bits)
+	}
 	return p.NewPart
 }
+
+// dmuxTreeImpl returns the implementation of a M-Way N-bits demultiplexer as a
+// tree of N-bits demultiplexers. If bits is 0, the inputs and outputs are
+// single pins instead of buses, as in DMuxNWay. If M is not a power of two,
+// the branches of the tree that lead to no output are left out.
+//
+func dmuxTreeImpl(name string, ways, bits int) hwsim.NewPartFn {
+	selBits := bts.Len8(uint8(ways - 1))
+	in, outs := pIn, strings.Join(inputNames[:ways], ", ")
+	if bits > 0 {
+		in, outs = busIO(bits, pIn), busIO(bits, inputNames[:ways]...)
+	}
+	return impl(name, in+", "+busIO(selBits, pSel), outs, func() []hwsim.Part {
+		var parts []hwsim.Part
+		dmux := DMux
+		if bits > 0 {
+			dmux = DMuxN(bits)
+		}
+		wires := []string{pIn}
+		for level := selBits - 1; level >= 0; level-- {
+			// next[i] leads to the outputs i<<level and up
+			next := make([]string, len(wires)*2)
+			for i := range next {
+				if i<<uint(level) >= ways {
+					break
+				}
+				next[i] = "l" + strconv.Itoa(level) + "_" + strconv.Itoa(i)
+				if level == 0 {
+					next[i] = inputNames[i]
+				}
+			}
+			for i, w := range wires {
+				if w == "" {
+					continue
+				}
+				c := "in=" + w + ", sel=sel[" + strconv.Itoa(level) + "], a=" + next[2*i]
+				if next[2*i+1] != "" {
+					c += ", b=" + next[2*i+1]
+				}
+				parts = append(parts, dmux(c))
+			}
+			wires = next
+		}
+		return parts
+	})
+}
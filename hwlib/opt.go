@@ -0,0 +1,652 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwlib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/db47h/hwsim"
+	"github.com/db47h/hwsim/internal/pins"
+	"github.com/pkg/errors"
+)
+
+// maxOptInputs is the maximum number of inputs of the gates and logic cones
+// handled by Optimize: their truth tables must fit in a uint64.
+//
+const maxOptInputs = 6
+
+// wire ids of constants
+const (
+	wFalse = iota
+	wTrue
+	wClk
+)
+
+// lane masks of the input variables in truth tables of up to 6 variables:
+// bit l of varMask[i] is set if bit i of l is set.
+//
+var varMask = [maxOptInputs]uint64{
+	0xAAAAAAAAAAAAAAAA,
+	0xCCCCCCCCCCCCCCCC,
+	0xF0F0F0F0F0F0F0F0,
+	0xFF00FF00FF00FF00,
+	0xFFFF0000FFFF0000,
+	0xFFFFFFFF00000000,
+}
+
+// Optimize returns an optimized version of a chip, usually a netlist
+// returned by hwsim.Flatten. It rewrites the sub-parts of the chip by
+//
+//   - folding constants: gates whose output is constant or equal to one of
+//     their inputs when some inputs are tied to true or false are removed.
+//   - removing double inversions.
+//   - merging identical gates, that is gates that compute the same function
+//     of the same wires.
+//   - removing dead logic: gates whose outputs are not used.
+//   - two-level minimization: fan-out free cones of gates with up to 6 inputs
+//     are replaced by a minimal sum of products built with And, Or and Not
+//     gates if it has fewer gates.
+//
+// Only combinational parts with a Bitwise function are optimized. Other parts,
+// like DFFs, are kept as is. The sub-parts of the chip are not expanded, so
+// nested chips are treated as black boxes.
+//
+// Each rewrite replaces logic with equivalent logic, so the returned part is
+// equivalent to the original one. This can be checked with
+// hwtest.Equivalent:
+//
+//	flat, err := hwsim.Flatten(part, hwlib.Nand, hwlib.DFF)
+//	// ...
+//	opt, err := hwlib.Optimize(flat)
+//	// ...
+//	if cex, err := hwtest.Equivalent(part, opt); err != nil || cex != nil {
+//		// ...
+//	}
+//
+func Optimize(part hwsim.NewPartFn) (hwsim.NewPartFn, error) {
+	ci := hwsim.Inspect(part)
+	if ci == nil {
+		return nil, errors.Errorf("part %s is not a chip", part("").Name)
+	}
+	o := newOptimizer(ci)
+	for {
+		o.fold()
+		o.sweep()
+		if !o.twoLevel() {
+			break
+		}
+	}
+	return o.chip()
+}
+
+type optGate struct {
+	spec *hwsim.PartSpec
+	ins  []int // input wires
+	outs []int // output wires, -1 if unconnected
+	dead bool
+}
+
+// comb returns true if the gate can be rewritten.
+//
+func (g *optGate) comb() bool {
+	return g.spec.Bitwise != nil && len(g.ins) <= maxOptInputs
+}
+
+type optimizer struct {
+	ci    *hwsim.ChipInfo
+	gates []*optGate
+	names []string    // original wire names, "" for new wires
+	alias []int       // alias[w] is a wire equivalent to w, or w
+	inv   map[int]int // inv[w] = x if w = !x
+	outs  []int       // wires of the chip outputs, -1 if unconnected
+}
+
+func newOptimizer(ci *hwsim.ChipInfo) *optimizer {
+	o := &optimizer{ci: ci}
+	ids := make(map[string]int)
+	for _, n := range []string{hwsim.False, hwsim.True, hwsim.Clk} {
+		ids[n] = o.wire(n)
+	}
+	for _, k := range ci.Inputs {
+		ids[k] = o.wire(k)
+	}
+	for _, w := range ci.Wires {
+		if _, ok := ids[w.Name]; !ok {
+			ids[w.Name] = o.wire(w.Name)
+		}
+	}
+	for _, p := range ci.Parts {
+		g := &optGate{spec: p.Spec, ins: make([]int, len(p.Spec.Inputs)), outs: make([]int, len(p.Spec.Outputs))}
+		for i, k := range p.Spec.Inputs {
+			if w, ok := p.Pins[k]; ok {
+				g.ins[i] = ids[w]
+			} else {
+				// unconnected inputs are tied to false
+				g.ins[i] = wFalse
+			}
+		}
+		for i, k := range p.Spec.Outputs {
+			g.outs[i] = -1
+			if w, ok := p.Pins[k]; ok {
+				g.outs[i] = ids[w]
+			}
+		}
+		o.gates = append(o.gates, g)
+	}
+	o.outs = make([]int, len(ci.Outputs))
+	for i := range o.outs {
+		o.outs[i] = -1
+	}
+	for _, w := range ci.Wires {
+		for _, s := range w.Sinks {
+			if s.Part < 0 {
+				for i, k := range ci.Outputs {
+					if k == s.Pin {
+						o.outs[i] = ids[w.Name]
+					}
+				}
+			}
+		}
+	}
+	return o
+}
+
+// wire returns a new wire.
+//
+func (o *optimizer) wire(name string) int {
+	w := len(o.alias)
+	o.alias = append(o.alias, w)
+	o.names = append(o.names, name)
+	return w
+}
+
+// find returns the representative of the wires equivalent to w.
+//
+func (o *optimizer) find(w int) int {
+	for o.alias[w] != w {
+		o.alias[w] = o.alias[o.alias[w]]
+		w = o.alias[w]
+	}
+	return w
+}
+
+// drivers returns the gates driving each wire, with the output index.
+//
+func (o *optimizer) drivers() (map[int]*optGate, map[int]int) {
+	drv := make(map[int]*optGate)
+	idx := make(map[int]int)
+	for _, g := range o.gates {
+		if g.dead {
+			continue
+		}
+		for i, w := range g.outs {
+			if w >= 0 {
+				drv[w], idx[w] = g, i
+			}
+		}
+	}
+	return drv, idx
+}
+
+// order returns the live gates in topological order. Parts without a Bitwise
+// function, like DFFs, break cycles.
+//
+func (o *optimizer) order() []*optGate {
+	drv, _ := o.drivers()
+	done := make(map[*optGate]bool)
+	var gs []*optGate
+	var visit func(g *optGate)
+	visit = func(g *optGate) {
+		if done[g] {
+			return
+		}
+		done[g] = true
+		if g.spec.Bitwise != nil {
+			for _, w := range g.ins {
+				if d := drv[o.find(w)]; d != nil {
+					visit(d)
+				}
+			}
+		}
+		gs = append(gs, g)
+	}
+	for _, g := range o.gates {
+		if !g.dead {
+			visit(g)
+		}
+	}
+	return gs
+}
+
+// table returns the truth tables of the outputs of a combinational gate, as
+// functions of vars, the distinct wires connected to its inputs, sorted.
+//
+func (o *optimizer) table(g *optGate) (tables []uint64, vars []int) {
+	for _, w := range g.ins {
+		if w > wTrue {
+			vars = append(vars, w)
+		}
+	}
+	sort.Ints(vars)
+	j := 0
+	for i, w := range vars {
+		if i == 0 || w != vars[j-1] {
+			vars[j] = w
+			j++
+		}
+	}
+	vars = vars[:j]
+	in := make([]uint64, len(g.ins))
+	for i, w := range g.ins {
+		switch w {
+		case wFalse:
+		case wTrue:
+			in[i] = ^uint64(0)
+		default:
+			in[i] = varMask[sort.SearchInts(vars, w)]
+		}
+	}
+	tables = make([]uint64, len(g.outs))
+	g.spec.Bitwise(in, tables)
+	return tables, vars
+}
+
+// fold folds constants, removes double inversions and merges identical gates.
+//
+func (o *optimizer) fold() {
+	// inverters recorded by a previous pass may have been rewritten by twoLevel
+	o.inv = make(map[int]int)
+	seen := make(map[string]*optGate)
+	for _, g := range o.order() {
+		for i, w := range g.ins {
+			g.ins[i] = o.find(w)
+		}
+		if g.spec.Bitwise == nil {
+			continue
+		}
+		key := fmt.Sprintf("%p%v", g.spec, g.ins)
+		if g.comb() {
+			ts, vars := o.table(g)
+			for i, w := range g.outs {
+				if w < 0 {
+					continue
+				}
+				if x, ok := o.reduce(ts[i], vars); ok {
+					o.alias[w] = x
+					g.outs[i] = -1
+				} else if x, ok := o.inverse(ts[i], vars); ok {
+					if y, ok := o.inv[x]; ok {
+						o.alias[w] = o.find(y)
+						g.outs[i] = -1
+					} else {
+						o.inv[w] = x
+					}
+				}
+			}
+			if len(g.outs) == 1 {
+				key = fmt.Sprintf("%x%v", ts[0], vars)
+			}
+		}
+		g.dead = true
+		for _, w := range g.outs {
+			if w >= 0 {
+				g.dead = false
+			}
+		}
+		if g.dead {
+			continue
+		}
+		if h := seen[key]; h != nil && len(h.outs) == len(g.outs) {
+			for i, w := range g.outs {
+				if w < 0 {
+					continue
+				}
+				if h.outs[i] < 0 {
+					h.outs[i] = w
+				} else {
+					o.alias[w] = h.outs[i]
+				}
+				g.outs[i] = -1
+			}
+			g.dead = true
+		} else {
+			seen[key] = g
+		}
+	}
+}
+
+// reduce returns the constant or variable equal to truth table t.
+//
+func (o *optimizer) reduce(t uint64, vars []int) (int, bool) {
+	switch t {
+	case 0:
+		return wFalse, true
+	case ^uint64(0):
+		return wTrue, true
+	}
+	for i, w := range vars {
+		if t == varMask[i] {
+			return w, true
+		}
+	}
+	return 0, false
+}
+
+// inverse returns the variable whose inverse is t.
+//
+func (o *optimizer) inverse(t uint64, vars []int) (int, bool) {
+	for i, w := range vars {
+		if t == ^varMask[i] {
+			return w, true
+		}
+	}
+	return 0, false
+}
+
+// sweep removes dead logic.
+//
+func (o *optimizer) sweep() {
+	drv, _ := o.drivers()
+	live := make(map[int]bool)
+	var mark func(w int)
+	mark = func(w int) {
+		w = o.find(w)
+		if live[w] {
+			return
+		}
+		live[w] = true
+		if g := drv[w]; g != nil && g.spec.Bitwise != nil {
+			for _, x := range g.ins {
+				mark(x)
+			}
+		}
+	}
+	for _, w := range o.outs {
+		if w >= 0 {
+			mark(w)
+		}
+	}
+	for _, g := range o.gates {
+		if !g.dead && g.spec.Bitwise == nil {
+			for _, w := range g.ins {
+				mark(w)
+			}
+		}
+	}
+	for _, g := range o.gates {
+		if g.dead || g.spec.Bitwise == nil {
+			continue
+		}
+		g.dead = true
+		for i, w := range g.outs {
+			if w >= 0 && !live[w] {
+				g.outs[i] = -1
+			}
+			if g.outs[i] >= 0 {
+				g.dead = false
+			}
+		}
+	}
+}
+
+// fanOut returns the number of gate inputs and chip outputs connected to each
+// wire.
+//
+func (o *optimizer) fanOut() map[int]int {
+	fo := make(map[int]int)
+	for _, g := range o.gates {
+		if !g.dead {
+			for _, w := range g.ins {
+				fo[o.find(w)]++
+			}
+		}
+	}
+	for _, w := range o.outs {
+		if w >= 0 {
+			fo[o.find(w)]++
+		}
+	}
+	return fo
+}
+
+// twoLevel replaces fan-out free cones of gates with a minimal sum of products
+// if it has fewer gates. It returns true if any cone has been replaced.
+//
+func (o *optimizer) twoLevel() bool {
+	gs := o.order()
+	drv, _ := o.drivers()
+	fo := o.fanOut()
+	single := func(g *optGate) bool { return g != nil && !g.dead && g.comb() && len(g.outs) == 1 }
+	changed := false
+	for i := len(gs) - 1; i >= 0; i-- {
+		r := gs[i]
+		if !single(r) {
+			continue
+		}
+		// grow the cone
+		cone := []*optGate{r}
+		in := map[*optGate]bool{r: true}
+		leaves := o.leaves(nil, r)
+		for grown := true; grown; {
+			grown = false
+			for _, w := range leaves {
+				d := drv[w]
+				if !single(d) || in[d] || fo[w] != 1 {
+					continue
+				}
+				ls := o.leaves(leaves, d)
+				j := 0
+				for _, x := range ls {
+					if x != w {
+						ls[j] = x
+						j++
+					}
+				}
+				if ls = ls[:j]; len(ls) > maxOptInputs {
+					continue
+				}
+				cone = append(cone, d)
+				in[d] = true
+				leaves = ls
+				grown = true
+				break
+			}
+		}
+		if len(cone) < 2 {
+			continue
+		}
+
+		// truth table of the cone
+		val := map[int]uint64{wTrue: ^uint64(0)}
+		for j, w := range leaves {
+			val[w] = varMask[j]
+		}
+		for j := len(cone) - 1; j >= 0; j-- {
+			g := cone[j]
+			ins := make([]uint64, len(g.ins))
+			for k, w := range g.ins {
+				ins[k] = val[o.find(w)]
+			}
+			out := make([]uint64, 1)
+			g.spec.Bitwise(ins, out)
+			val[g.outs[0]] = out[0]
+		}
+		t := val[r.outs[0]]
+
+		n := len(leaves)
+		var on, off []uint
+		for m := uint(0); m < 1<<uint(n); m++ {
+			if t&(1<<m) != 0 {
+				on = append(on, m)
+			} else {
+				off = append(off, m)
+			}
+		}
+		out := r.outs[0]
+		for _, g := range cone {
+			g.dead = true
+		}
+		if x, ok := o.reduce(t, leaves); ok {
+			o.alias[out] = x
+			changed = true
+			continue
+		}
+		terms, neg := minimize(n, on, nil), false
+		cost := sopGates(terms)
+		if nt := minimize(n, off, nil); sopGates(nt)+1 < cost {
+			terms, neg, cost = nt, true, sopGates(nt)+1
+		}
+		if cost >= len(cone) {
+			for _, g := range cone {
+				g.dead = false
+			}
+			continue
+		}
+		w := out
+		if neg {
+			w = o.wire("")
+			o.gate(&notGate, []int{w}, out)
+		}
+		sop(terms, leaves, w, func() int { return o.wire("") }, o.gate)
+		changed = true
+		fo = o.fanOut()
+	}
+	return changed
+}
+
+// leaves appends to ls the non-constant wires connected to the inputs of g that
+// are not already in ls.
+//
+func (o *optimizer) leaves(ls []int, g *optGate) []int {
+	ls = append([]int(nil), ls...)
+outer:
+	for _, w := range g.ins {
+		if w = o.find(w); w <= wTrue {
+			continue
+		}
+		for _, x := range ls {
+			if x == w {
+				continue outer
+			}
+		}
+		ls = append(ls, w)
+	}
+	return ls
+}
+
+// gate adds a new gate.
+//
+func (o *optimizer) gate(spec *hwsim.PartSpec, ins []int, out int) {
+	o.gates = append(o.gates, &optGate{spec: spec, ins: ins, outs: []int{out}})
+}
+
+// chip builds the optimized chip.
+//
+func (o *optimizer) chip() (hwsim.NewPartFn, error) {
+	ci := o.ci
+	drv, idx := o.drivers()
+	read := make(map[int]bool)
+	for _, g := range o.gates {
+		if !g.dead {
+			for _, w := range g.ins {
+				read[o.find(w)] = true
+			}
+		}
+	}
+
+	// wire names. Names of chip pins and buses are reserved, and a name cannot
+	// be used both for a single wire and a bus.
+	reserved := make(map[string]bool)
+	for _, ps := range [][]string{ci.Inputs, ci.Outputs} {
+		for _, p := range ps {
+			reserved[p] = true
+			if i := strings.IndexByte(p, '['); i >= 0 {
+				reserved[p[:i]] = true
+			}
+		}
+	}
+	used := make(map[string]bool)
+	bus := make(map[string]bool)
+	ok := func(n, base, idx string) bool {
+		switch {
+		case reserved[n] || reserved[base] || used[n]:
+			return false
+		case idx == "":
+			return !bus[n]
+		default:
+			return !used[base]
+		}
+	}
+	names := make(map[int]string)
+	for w := wFalse; w <= wClk; w++ {
+		names[w] = o.names[w]
+	}
+	for i, k := range ci.Inputs {
+		names[wClk+1+i] = k
+	}
+	name := func(w int) string {
+		if n, ok := names[w]; ok {
+			return n
+		}
+		n := o.names[w]
+		if n == "" {
+			n = "t"
+		}
+		n = pins.Ident(n)
+		base, idx := n, ""
+		if i := strings.IndexByte(n, '['); i >= 0 {
+			base, idx = n[:i], n[i:]
+		}
+		for i := 1; !ok(n, base, idx); i++ {
+			base = strings.TrimRight(base, "0123456789") + strconv.Itoa(i)
+			n = base + idx
+		}
+		used[n] = true
+		if idx != "" {
+			bus[base] = true
+		}
+		names[w] = n
+		return n
+	}
+
+	conns := make(map[*optGate][]string)
+	var bufs []hwsim.Part
+	for i, w := range o.outs {
+		if w < 0 {
+			continue
+		}
+		k := ci.Outputs[i]
+		w = o.find(w)
+		if g := drv[w]; g != nil {
+			conns[g] = append(conns[g], g.spec.Outputs[idx[w]]+"="+k)
+		} else {
+			n := name(w)
+			bufs = append(bufs, And("a="+n+", b="+n+", out="+k))
+		}
+	}
+	var parts []hwsim.Part
+	for _, g := range o.gates {
+		if g.dead {
+			continue
+		}
+		var cs []string
+		for i, w := range g.ins {
+			if w = o.find(w); w != wFalse {
+				cs = append(cs, g.spec.Inputs[i]+"="+name(w))
+			}
+		}
+		for i, w := range g.outs {
+			if w >= 0 && read[w] {
+				cs = append(cs, g.spec.Outputs[i]+"="+name(w))
+			}
+		}
+		cs = append(cs, conns[g]...)
+		parts = append(parts, g.spec.NewPart(strings.Join(cs, ", ")))
+	}
+	parts = append(parts, bufs...)
+	return hwsim.Chip(ci.Name, pins.IOSpec(ci.Inputs), pins.IOSpec(ci.Outputs), parts...)
+}
@@ -0,0 +1,223 @@
+package hwlib_test
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	hw "github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+	"github.com/db47h/hwsim/hwtest"
+)
+
+func TestOptimize(t *testing.T) {
+	// a & !b | c, with redundant logic
+	redundant, err := hw.Chip("R", "a, b, c", "out, t, na",
+		hl.Not("in=a, out=na0"),
+		hl.Not("in=na0, out=a0"),       // double inversion
+		hl.And("a=a0, b=true, out=a1"), // constant
+		hl.Nand("a=b, b=b, out=nb"),    // NOT
+		hl.Not("in=b, out=nb1"),        // same as nb
+		hl.And("a=a1, b=nb, out=x"),
+		hl.And("a=nb1, b=a, out=y"), // same as x
+		hl.Or("a=x, b=c, out=out"),
+		hl.Or("a=y, b=false, out=unused"), // dead
+		hl.Nand("a=false, b=c, out=t"),    // constant output
+		hl.Xor("a=a, b=true, out=na"),     // inverter tied to an output
+		hl.And("a=c, b=unused"),           // dead
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt, err := hl.Optimize(redundant)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareFormal(t, redundant, opt)
+	// x = AND(a, NOT b), na = NOT a, out, t buffer
+	if n := len(hw.Inspect(opt).Parts); n != 5 {
+		for _, p := range hw.Inspect(opt).Parts {
+			t.Log(p.Spec.Name, p.Pins)
+		}
+		t.Errorf("got %d parts, expected 5", n)
+	}
+
+	// twoLevel rewrites the inverter w2, which must not be reused by fold.
+	inv, err := hw.Chip("R", "a, b, c", "o",
+		hl.Nor("a=a, b=b, out=w0"),
+		hl.Not("in=c, out=w1"),
+		hl.Not("in=w0, out=w2"),
+		hl.Xor("a=w2, b=w1, out=w4"),
+		hl.Nor("a=w4, b=w2, out=o"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt, err = hl.Optimize(inv); err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareFormal(t, inv, opt)
+
+	// sequential parts are kept
+	reg, err := hw.Chip("Reg2", "in[2], load", "out[2]",
+		hl.MuxN(2)("a=q, b=in, sel=load, out=d"),
+		hl.DFFN(2)("in=d, out=q, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flat, err := hw.Flatten(reg, hl.Nand, hl.DFF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opt, err = hl.Optimize(flat); err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareSequential(t, reg, opt, nil)
+
+	if _, err = hl.Optimize(hl.Nand); err == nil {
+		t.Error("no error for a part that is not a chip")
+	}
+}
+
+// randomChip returns a random netlist of 1 to 20 gates with 3 inputs and up to
+// 3 outputs. The inputs of each gate are chip inputs, constants or outputs of
+// previous gates.
+//
+func randomChip(r *rand.Rand) (hw.NewPartFn, error) {
+	gates := []struct {
+		part func(string) hw.Part
+		ins  []string
+	}{
+		{hl.Not, []string{"in"}},
+		{hl.Not, []string{"in"}},
+		{hl.And, []string{"a", "b"}},
+		{hl.Nand, []string{"a", "b"}},
+		{hl.Or, []string{"a", "b"}},
+		{hl.Nor, []string{"a", "b"}},
+		{hl.Xor, []string{"a", "b"}},
+		{hl.Xnor, []string{"a", "b"}},
+		{hl.Mux, []string{"a", "b", "sel"}},
+	}
+	ws := []string{"a", "b", "c", "a", "b", "c", hw.False, hw.True}
+	n := 1 + r.Intn(20)
+	outs := 1 + r.Intn(3)
+	if outs > n {
+		outs = n
+	}
+	type gate struct {
+		part  func(string) hw.Part
+		conns []string
+	}
+	gs := make([]gate, n)
+	read := make(map[string]bool)
+	for i := range gs {
+		g := gates[r.Intn(len(gates))]
+		gs[i].part = g.part
+		for _, k := range g.ins {
+			// prefer recent wires to build deeper logic
+			w := ws[r.Intn(len(ws))]
+			if r.Intn(2) == 0 {
+				w = ws[len(ws)-1-r.Intn(3)]
+			}
+			read[w] = true
+			gs[i].conns = append(gs[i].conns, k+"="+w)
+		}
+		ws = append(ws, "w"+strconv.Itoa(i))
+	}
+	var os []string
+	parts := make([]hw.Part, n)
+	for i, g := range gs {
+		w := "w" + strconv.Itoa(i)
+		cs := g.conns
+		if read[w] {
+			cs = append(cs, "out="+w)
+		}
+		if i >= n-outs {
+			o := "o" + strconv.Itoa(i)
+			os = append(os, o)
+			cs = append(cs, "out="+o)
+		}
+		parts[i] = g.part(strings.Join(cs, ", "))
+	}
+	return hw.Chip("R", "a, b, c", strings.Join(os, ", "), parts...)
+}
+
+// TestOptimize_random checks that random netlists are optimized into
+// equivalent chips.
+//
+func TestOptimize_random(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	n := 6000
+	if testing.Short() {
+		n = 500
+	}
+	for i := 0; i < n; i++ {
+		part, err := randomChip(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opt, err := hl.Optimize(part)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, netlist(part))
+		}
+		cex, err := hwtest.Equivalent(part, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cex != nil {
+			t.Fatalf("optimized chip differs for inputs %v\n%s", cex, netlist(part))
+		}
+	}
+}
+
+// TestOptimize_flatten checks that flattened parts are optimized into
+// equivalent chips that are no larger than the original.
+//
+func TestOptimize_flatten(t *testing.T) {
+	td := []struct {
+		part  hw.NewPartFn
+		prims []hw.NewPartFn
+	}{
+		{hl.MuxMWayN(4, 2), []hw.NewPartFn{hl.Nand}},
+		{hl.DMuxMWayN(8, 1), []hw.NewPartFn{hl.Nand}},
+		{hl.OrNWay(8), []hw.NewPartFn{hl.Nand}},
+		{hl.Xnor, []hw.NewPartFn{hl.Nand}},
+		{hl.Mux, []hw.NewPartFn{hl.And, hl.Or, hl.Not, hl.Nand}},
+	}
+	for _, d := range td {
+		t.Run(d.part("").Name, func(t *testing.T) {
+			flat, err := hw.Flatten(d.part, d.prims...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			opt, err := hl.Optimize(flat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hwtest.CompareFormal(t, d.part, opt)
+			n, m := len(hw.Inspect(opt).Parts), len(hw.Inspect(flat).Parts)
+			if n > m {
+				t.Errorf("optimized part has %d parts, more than the %d parts of the original", n, m)
+			}
+			t.Logf("%d -> %d parts", m, n)
+		})
+	}
+}
+
+// netlist returns a description of the parts of a chip.
+//
+func netlist(part hw.NewPartFn) string {
+	var b strings.Builder
+	for _, p := range hw.Inspect(part).Parts {
+		b.WriteString(p.Spec.Name)
+		for _, k := range append(append([]string(nil), p.Spec.Inputs...), p.Spec.Outputs...) {
+			if w, ok := p.Pins[k]; ok {
+				b.WriteString(" " + k + "=" + w)
+			}
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
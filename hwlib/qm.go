@@ -0,0 +1,237 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwlib
+
+import (
+	"sort"
+
+	"github.com/db47h/hwsim"
+)
+
+// A cube is a product term of a boolean function. Variable i appears in the
+// term if bit i of mask is set, negated if bit i of val is clear.
+//
+type cube struct {
+	mask, val uint
+}
+
+// covers returns true if minterm m is covered by c.
+//
+func (c cube) covers(m uint) bool {
+	return m&c.mask == c.val
+}
+
+// literals returns the number of literals in c.
+//
+func (c cube) literals() int {
+	n := 0
+	for m := c.mask; m != 0; m &= m - 1 {
+		n++
+	}
+	return n
+}
+
+// minimize returns a minimal sum of products of the n variables function
+// whose true minterms are listed in on, using the minterms in dc as don't
+// cares. It uses the Quine-McCluskey algorithm to find the prime implicants
+// and selects the essential ones first, then the ones that cover the most
+// remaining minterms.
+//
+// It returns nil for the constant false function and a single cube with an
+// empty mask for the constant true function.
+//
+func minimize(n int, on, dc []uint) []cube {
+	if len(on) == 0 {
+		return nil
+	}
+	full := uint(1)<<uint(n) - 1
+	cur := make(map[cube]bool, len(on)+len(dc))
+	for _, m := range on {
+		cur[cube{full, m}] = true
+	}
+	for _, m := range dc {
+		cur[cube{full, m}] = true
+	}
+
+	// prime implicants
+	var primes []cube
+	for len(cur) > 0 {
+		next := make(map[cube]bool)
+		merged := make(map[cube]bool)
+		for c := range cur {
+			for b := c.mask; b != 0; b &= b - 1 {
+				bit := b & -b
+				if c.val&bit != 0 {
+					continue
+				}
+				if d := (cube{c.mask, c.val | bit}); cur[d] {
+					next[cube{c.mask &^ bit, c.val}] = true
+					merged[c], merged[d] = true, true
+				}
+			}
+		}
+		for c := range cur {
+			if !merged[c] {
+				primes = append(primes, c)
+			}
+		}
+		cur = next
+	}
+	sort.Slice(primes, func(i, j int) bool {
+		if li, lj := primes[i].literals(), primes[j].literals(); li != lj {
+			return li < lj
+		}
+		if primes[i].mask != primes[j].mask {
+			return primes[i].mask < primes[j].mask
+		}
+		return primes[i].val < primes[j].val
+	})
+
+	// cover
+	left := make(map[uint]bool, len(on))
+	for _, m := range on {
+		left[m] = true
+	}
+	var terms []cube
+	used := make([]bool, len(primes))
+	pick := func(i int) {
+		used[i] = true
+		terms = append(terms, primes[i])
+		for m := range left {
+			if primes[i].covers(m) {
+				delete(left, m)
+			}
+		}
+	}
+	for _, m := range on {
+		if !left[m] {
+			continue
+		}
+		k := -1
+		for i, p := range primes {
+			if p.covers(m) {
+				if k >= 0 {
+					k = -1
+					break
+				}
+				k = i
+			}
+		}
+		if k >= 0 {
+			pick(k)
+		}
+	}
+	for len(left) > 0 {
+		best, bn := -1, 0
+		for i, p := range primes {
+			if used[i] {
+				continue
+			}
+			n := 0
+			for m := range left {
+				if p.covers(m) {
+					n++
+				}
+			}
+			if n > bn {
+				best, bn = i, n
+			}
+		}
+		pick(best)
+	}
+	return terms
+}
+
+// sopGates returns the number of gates of the AND/OR/NOT network built by sop
+// for the given terms.
+//
+func sopGates(terms []cube) int {
+	var neg uint
+	n := len(terms) - 1
+	for _, t := range terms {
+		neg |= t.mask &^ t.val
+		n += t.literals() - 1
+	}
+	for ; neg != 0; neg &= neg - 1 {
+		n++
+	}
+	if n == 0 {
+		// buffer for a single positive literal
+		n = 1
+	}
+	return n
+}
+
+// sop builds the two-level AND/OR/NOT network of a sum of products, with
+// negated variables shared between terms. vars are the wires of the variables
+// and out the wire of the result. wire is called to get new intermediate
+// wires, and gate for each gate of the network.
+//
+// terms must not be empty and must not contain a cube with an empty mask.
+//
+func sop(terms []cube, vars []int, out int, wire func() int, gate func(g *hwsim.PartSpec, ins []int, out int)) {
+	if t := terms[0]; len(terms) == 1 && t.literals() == 1 {
+		v := vars[bitIndex(t.mask)]
+		if t.val == 0 {
+			gate(&notGate, []int{v}, out)
+		} else {
+			gate(and, []int{v, v}, out)
+		}
+		return
+	}
+	not := make(map[int]int)
+	lit := func(i int, neg bool) int {
+		if !neg {
+			return vars[i]
+		}
+		w, ok := not[i]
+		if !ok {
+			w = wire()
+			gate(&notGate, []int{vars[i]}, w)
+			not[i] = w
+		}
+		return w
+	}
+	// chain reduces ws with g. The last gate drives o if o >= 0.
+	chain := func(g *hwsim.PartSpec, ws []int, o int) int {
+		w := ws[0]
+		for i := 1; i < len(ws); i++ {
+			x := o
+			if i < len(ws)-1 || o < 0 {
+				x = wire()
+			}
+			gate(g, []int{w, ws[i]}, x)
+			w = x
+		}
+		return w
+	}
+	var ts []int
+	for _, t := range terms {
+		var ls []int
+		for i := range vars {
+			if bit := uint(1) << uint(i); t.mask&bit != 0 {
+				ls = append(ls, lit(i, t.val&bit == 0))
+			}
+		}
+		o := -1
+		if len(terms) == 1 {
+			o = out
+		}
+		ts = append(ts, chain(and, ls, o))
+	}
+	if len(terms) > 1 {
+		chain(or, ts, out)
+	}
+}
+
+// bitIndex returns the index of the lowest set bit of m.
+//
+func bitIndex(m uint) int {
+	i := 0
+	for m&1 == 0 {
+		m >>= 1
+		i++
+	}
+	return i
+}
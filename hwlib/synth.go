@@ -0,0 +1,240 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwlib
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/db47h/hwsim"
+	"github.com/db47h/hwsim/internal/pins"
+	"github.com/pkg/errors"
+)
+
+// maxSynthInputs is the maximum number of inputs of the parts built from truth
+// tables and expressions.
+//
+const maxSynthInputs = 16
+
+// FromTruthTable returns a part built from its truth table, as a chip of AND,
+// OR and NOT gates. The gate network of each output is a sum of products
+// minimized with the Quine-McCluskey algorithm. Identical gates are shared
+// between outputs.
+//
+// The inputs and outputs are I/O specifications, as for hwsim.Chip. table[i]
+// holds the output values for input values i, where bit j of i is the value of
+// the j-th input pin, and bit k of table[i] is the value of the k-th output
+// pin. For example, the truth table of a half adder is:
+//
+//	ha, err := hwlib.FromTruthTable("HA", "a, b", "sum, carry", []uint64{
+//		0x0, // a=0, b=0: sum=0, carry=0
+//		0x1, // a=1, b=0: sum=1, carry=0
+//		0x1, // a=0, b=1: sum=1, carry=0
+//		0x2, // a=1, b=1: sum=0, carry=1
+//	})
+//
+// With buses, pins are numbered in the order of the expanded I/O specification:
+// "a, b[2]" is a, b[0], b[1]. The part can have up to 16 inputs and 64 outputs,
+// but minimization is slow for parts with more than 10 inputs. See
+// TruthTableLUT for a faster alternative.
+//
+func FromTruthTable(name, inputs, outputs string, table []uint64) (hwsim.NewPartFn, error) {
+	ins, outs, err := checkTable(inputs, outputs, table)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return synth(name, ins, outs, table)
+}
+
+// TruthTableLUT is a fast version of FromTruthTable. It returns a custom part
+// that looks up its output values in table, with a gate-level implementation
+// (see hwsim.PartSpec.Impl) built by FromTruthTable. The implementation is
+// only built when used, by tools like hwsim.Flatten or hwtest.Equivalent.
+//
+func TruthTableLUT(name, inputs, outputs string, table []uint64) (hwsim.NewPartFn, error) {
+	ins, outs, err := checkTable(inputs, outputs, table)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return lutSpec(name, ins, outs, table).NewPart, nil
+}
+
+// LUT returns a lookup table part, like the LUTs of FPGAs: its output values
+// are read from table, indexed by its input values. Bit i of the index is the
+// value of in[i], and bit i of table[index] is the value of out[i].
+//
+// Its gate-level implementation is built by FromTruthTable when used. LUT
+// panics if inputs is not in the range [0, 16], if outputs is not in the
+// range [1, 64], or if table does not have 1<<inputs entries.
+//
+//	Inputs: in[inputs]
+//	Outputs: out[outputs]
+//	Function: out = table[in]
+//
+func LUT(inputs, outputs int, table []uint64) hwsim.NewPartFn {
+	switch {
+	case inputs < 0:
+		panic("LUT: negative number of inputs")
+	case outputs < 0:
+		panic("LUT: negative number of outputs")
+	}
+	ins, outs := bus(inputs, pIn), bus(outputs, pOut)
+	if _, _, err := checkTable(pins.IOSpec(ins), pins.IOSpec(outs), table); err != nil {
+		panic("LUT: " + err.Error())
+	}
+	return lutSpec("LUT"+strconv.Itoa(inputs), ins, outs, table).NewPart
+}
+
+// checkTable checks a truth table against the given I/O specs and returns
+// the input and output pin names.
+//
+func checkTable(inputs, outputs string, table []uint64) (ins, outs []string, err error) {
+	if ins, err = hwsim.ParseIOSpec(inputs); err != nil {
+		return nil, nil, err
+	}
+	if outs, err = hwsim.ParseIOSpec(outputs); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case len(ins) > maxSynthInputs:
+		return nil, nil, errors.Errorf("%d inputs, at most %d supported", len(ins), maxSynthInputs)
+	case len(outs) == 0:
+		return nil, nil, errors.New("no outputs")
+	case len(outs) > 64:
+		return nil, nil, errors.Errorf("%d outputs, at most 64 supported", len(outs))
+	case len(table) != 1<<uint(len(ins)):
+		return nil, nil, errors.Errorf("truth table has %d entries, expected %d", len(table), 1<<uint(len(ins)))
+	}
+	if len(outs) < 64 {
+		for i, v := range table {
+			if v>>uint(len(outs)) != 0 {
+				return nil, nil, errors.Errorf("truth table entry %d: value %#x has more than %d bits", i, v, len(outs))
+			}
+		}
+	}
+	return ins, outs, nil
+}
+
+// synth builds the gate network of a truth table.
+//
+func synth(name string, ins, outs []string, table []uint64) (hwsim.NewPartFn, error) {
+	// wire names: inputs, outputs, then intermediate wires.
+	reserved := make(map[string]bool)
+	for _, ps := range [][]string{ins, outs} {
+		for _, p := range ps {
+			reserved[p] = true
+			if i := strings.IndexByte(p, '['); i >= 0 {
+				reserved[p[:i]] = true
+			}
+		}
+	}
+	names := append(append([]string(nil), ins...), outs...)
+	wire := func() int {
+		n := "t" + strconv.Itoa(len(names))
+		for reserved[n] {
+			n = "_" + n
+		}
+		names = append(names, n)
+		return len(names) - 1
+	}
+	var parts []hwsim.Part
+	gate := func(g *hwsim.PartSpec, in []int, out int) {
+		cs := make([]string, 0, len(in)+1)
+		for i, w := range in {
+			cs = append(cs, g.Inputs[i]+"="+names[w])
+		}
+		parts = append(parts, g.NewPart(strings.Join(append(cs, g.Outputs[0]+"="+names[out]), ", ")))
+	}
+
+	vars := make([]int, len(ins))
+	for i := range vars {
+		vars[i] = i
+	}
+	for k := range outs {
+		var on []uint
+		for i, v := range table {
+			if v&(1<<uint(k)) != 0 {
+				on = append(on, uint(i))
+			}
+		}
+		terms := minimize(len(ins), on, nil)
+		switch {
+		case len(terms) == 0:
+			parts = append(parts, Not("in=true, out="+outs[k]))
+		case terms[0].mask == 0:
+			parts = append(parts, Not("in=false, out="+outs[k]))
+		default:
+			sop(terms, vars, len(ins)+k, wire, gate)
+		}
+	}
+	chip, err := hwsim.Chip(name, pins.IOSpec(ins), pins.IOSpec(outs), parts...)
+	if err != nil {
+		return nil, err
+	}
+	return Optimize(chip)
+}
+
+// lutSpec returns the PartSpec of a custom part that looks up its output
+// values in a copy of table. See FromTruthTable for the table format. Its
+// gate-level implementation is built by synth on first use.
+//
+func lutSpec(name string, ins, outs []string, table []uint64) *hwsim.PartSpec {
+	table = append([]uint64(nil), table...)
+	var (
+		once sync.Once
+		impl hwsim.NewPartFn
+		err  error
+	)
+	return &hwsim.PartSpec{
+		Name:    name,
+		Inputs:  ins,
+		Outputs: outs,
+		Mount: func(s *hwsim.Socket) hwsim.Updater {
+			in := make([]*hwsim.Wire, len(ins))
+			for i, k := range ins {
+				in[i] = s.Wire(k)
+			}
+			out := make([]*hwsim.Wire, len(outs))
+			for i, k := range outs {
+				out[i] = s.Wire(k)
+			}
+			return hwsim.UpdaterFn(func(clk bool) {
+				var idx uint
+				for i, w := range in {
+					if w.Recv(clk) {
+						idx |= 1 << uint(i)
+					}
+				}
+				v := table[idx]
+				for i, w := range out {
+					w.Send(clk, v&(1<<uint(i)) != 0)
+				}
+			})
+		},
+		Bitwise: func(in, out []uint64) {
+			for i := range out {
+				out[i] = 0
+			}
+			for l := uint(0); l < 64; l++ {
+				var idx uint64
+				for i, v := range in {
+					idx |= (v >> l & 1) << uint(i)
+				}
+				v := table[idx]
+				for i := range out {
+					out[i] |= (v >> uint(i) & 1) << l
+				}
+			}
+		},
+		Impl: func(c string) hwsim.Part {
+			// keep the error so that every call fails the same way
+			once.Do(func() { impl, err = synth(name, ins, outs, table) })
+			if err != nil {
+				panic(err)
+			}
+			return impl(c)
+		},
+	}
+}
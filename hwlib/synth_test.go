@@ -0,0 +1,218 @@
+package hwlib_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	hw "github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+	"github.com/db47h/hwsim/hwtest"
+)
+
+// 7-segment display decoder for hex digits. Bit 0 is segment a.
+//
+var sevenSeg = []uint64{
+	0x3F, 0x06, 0x5B, 0x4F, 0x66, 0x6D, 0x7D, 0x07,
+	0x7F, 0x6F, 0x77, 0x7C, 0x39, 0x5E, 0x79, 0x71,
+}
+
+// checkGates checks that part is a chip of AND, OR and NOT gates only.
+//
+func checkGates(t *testing.T, part hw.NewPartFn) {
+	t.Helper()
+	ok := map[*hw.PartSpec]bool{
+		hl.And("").PartSpec: true,
+		hl.Or("").PartSpec:  true,
+		hl.Not("").PartSpec: true,
+	}
+	for _, p := range hw.Inspect(part).Parts {
+		if !ok[p.Spec] {
+			t.Errorf("unexpected part %s", p.Spec.Name)
+		}
+	}
+}
+
+func TestFromTruthTable(t *testing.T) {
+	ha, err := hl.FromTruthTable("HA", "a, b", "sum, carry", []uint64{0, 1, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGates(t, ha)
+	ref, err := hw.Chip("HA", "a, b", "sum, carry",
+		hl.Xor("a=a, b=b, out=sum"),
+		hl.And("a=a, b=b, out=carry"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareFormal(t, ref, ha)
+
+	seg, err := hl.FromTruthTable("SEG7", "in[4]", "seg[7]", sevenSeg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGates(t, seg)
+	lut, err := hl.TruthTableLUT("SEG7", "in[4]", "seg[7]", sevenSeg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.TruthTable(t, lut, `
+		|  in  |  seg   |
+		| 0000 | 0111111 |
+		| 0001 | 0000110 |
+		| 1000 | 1111111 |
+		| 1111 | 1110001 |`)
+	hwtest.ComparePart(t, lut, seg)
+	hwtest.CompareFormal(t, lut("").Impl, seg)
+
+	// constant outputs
+	cst, err := hl.FromTruthTable("CST", "a", "f, t, a0, na", []uint64{0xA, 0x6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cstLUT, err := hl.TruthTableLUT("CST", "a", "f, t, a0, na", []uint64{0xA, 0x6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.ComparePart(t, cstLUT, cst)
+
+	errs := []struct {
+		ins, outs string
+		table     []uint64
+	}{
+		{"a, b", "out", []uint64{0, 1, 1}},
+		{"a", "out", []uint64{0, 2}},
+		{"a", "", []uint64{0, 1}},
+		{"a[17]", "out", nil},
+		{"a[", "out", []uint64{0, 1}},
+	}
+	for _, e := range errs {
+		if _, err = hl.FromTruthTable("E", e.ins, e.outs, e.table); err == nil {
+			t.Errorf("%s -> %s %v: no error", e.ins, e.outs, e.table)
+		}
+		if _, err = hl.TruthTableLUT("E", e.ins, e.outs, e.table); err == nil {
+			t.Errorf("%s -> %s %v: no error", e.ins, e.outs, e.table)
+		}
+	}
+}
+
+func TestFromExpr(t *testing.T) {
+	ha, err := hw.Chip("HA", "a, b", "carry, sum",
+		hl.And("a=a, b=b, out=carry"),
+		hl.Xor("a=a, b=b, out=sum"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td := []struct {
+		expr string
+		ref  hw.NewPartFn
+	}{
+		{"out = a & ~sel | b & sel", hl.Mux},
+		{"out = !((a & b) | (!a & !b))", hl.Xor},
+		{"out = a ^ b ^ 1", hl.Xnor},
+		{"a = in & ~sel\nb = in & sel", hl.DMux},
+		{"out = ~(a & b)", hl.Nand},
+		{"carry = a & b; sum = (a | b) & ~carry", ha},
+	}
+	for _, d := range td {
+		t.Run(d.ref("").Name, func(t *testing.T) {
+			p, err := hl.FromExpr(d.ref("").Name, d.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGates(t, p)
+			hwtest.CompareFormal(t, d.ref, p)
+			lut, err := hl.ExprLUT(d.ref("").Name, d.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hwtest.ComparePart(t, d.ref, lut)
+		})
+	}
+
+	errs := []struct {
+		expr, msg string
+	}{
+		{"", "E: no outputs"},
+		{"out = a &", `E: in "out = a &" at pos 10: unexpected end of expression`},
+		{"out = (a", `E: in "out = (a" at pos 9: expected ), got ""`},
+		{"out a", `E: in "out a" at pos 5: expected =, got "a"`},
+		{"out = a b", `E: in "out = a b" at pos 9: unexpected "b"`},
+		{"out = a; out = b", `E: in "out = a; out = b" at pos 10: output out assigned twice`},
+		{"x = a; a = b", `E: in "x = a; a = b" at pos 8: output a already used as an input`},
+		{"& = a", `E: in "& = a" at pos 1: expected output name, got "&"`},
+		{"x = a; true = b", `E: in "x = a; true = b" at pos 8: reserved name true used as output`},
+		{"clk = a", `E: in "clk = a" at pos 1: reserved name clk used as output`},
+		{"out = out & a", `E: in "out = out & a" at pos 7: output out used in its own expression`},
+		{"x = a; out = x | ~(b & out)", `E: in "x = a; out = x | ~(b & out)" at pos 24: output out used in its own expression`},
+	}
+	for _, e := range errs {
+		_, err := hl.FromExpr("E", e.expr)
+		if err == nil {
+			t.Errorf("%q: no error", e.expr)
+			continue
+		}
+		if err.Error() != e.msg {
+			t.Errorf("%q: got error %q, expected %q", e.expr, err, e.msg)
+		}
+	}
+}
+
+func TestLUT(t *testing.T) {
+	seg, err := hl.FromTruthTable("SEG7", "in[4]", "out[7]", sevenSeg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.ComparePart(t, hl.LUT(4, 7, sevenSeg), seg)
+
+	// a full adder mapped to 3 inputs LUTs
+	fa, err := hw.Chip("FA", "a, b, c", "sum, carry",
+		hl.LUT(3, 1, []uint64{0, 1, 1, 0, 1, 0, 0, 1})("in[0]=a, in[1]=b, in[2]=c, out[0]=sum"),
+		hl.LUT(3, 1, []uint64{0, 0, 0, 1, 0, 1, 1, 1})("in[0]=a, in[1]=b, in[2]=c, out[0]=carry"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := hl.FromExpr("FA", "sum = a ^ b ^ c; carry = a & b | a & c | b & c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.ComparePart(t, ref, fa)
+	hwtest.CompareFormal(t, ref, fa)
+	flat, err := hw.Flatten(fa, hl.Nand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareFormal(t, ref, flat)
+
+	not, err := hw.Chip("NOT", "in", "out", hl.LUT(1, 1, []uint64{1, 0})("in[0]=in, out[0]=out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareFormal(t, hl.Not, not)
+
+	bad := []struct {
+		inputs, outputs int
+		table           []uint64
+	}{
+		{2, 1, []uint64{0, 1}},
+		{1, 1, []uint64{0, 2}},
+		{1, 0, []uint64{0, 0}},
+		{1, 65, []uint64{0, 0}},
+		{17, 1, make([]uint64, 1<<17)},
+		{-1, 1, nil},
+		{1, -1, []uint64{0, 0}},
+	}
+	for _, b := range bad {
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.HasPrefix(fmt.Sprint(r), "LUT: ") {
+					t.Errorf("LUT(%d, %d, %d entries): got panic %v", b.inputs, b.outputs, len(b.table), r)
+				}
+			}()
+			hl.LUT(b.inputs, b.outputs, b.table)
+		}()
+	}
+}
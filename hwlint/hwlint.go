@@ -0,0 +1,243 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+// Package hwlint provides configurable style checks for chips built with
+// hwsim.
+//
+// Checks are implemented as Rules that inspect a Design, that is a part
+// mounted in a test circuit. The rules provided by this package cover common
+// assignment requirements, like building a chip from Nand gates only:
+//
+//	problems, err := hwlint.Check(myChip,
+//		hwlint.OnlyParts("NAND"),
+//		hwlint.MaxFanOut(8),
+//		hwlint.Wiring(),
+//	)
+//
+package hwlint
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/db47h/hwsim"
+)
+
+// A Problem is a rule violation.
+//
+type Problem struct {
+	Rule  string // name of the rule that reported the problem
+	Where string // hierarchical name of the offending chip, part or wire
+	Msg   string // description of the problem
+}
+
+func (p *Problem) String() string {
+	return p.Rule + ": " + p.Where + ": " + p.Msg
+}
+
+// A Design is a part under inspection.
+//
+type Design struct {
+	Part    hwsim.NewPartFn
+	Circuit *hwsim.Circuit // test circuit where the part's inputs and outputs are connected to wires named after them
+	comps   []*hwsim.Component
+}
+
+// Components returns the leaf components of the part, excluding the parts
+// that drive the test circuit.
+//
+func (d *Design) Components() []*hwsim.Component {
+	return d.comps
+}
+
+// A Rule checks a Design and returns the problems found.
+//
+type Rule func(d *Design) []Problem
+
+// Check runs the given rules on a part and returns all the problems found,
+// rule by rule.
+//
+func Check(part hwsim.NewPartFn, rules ...Rule) ([]Problem, error) {
+	spec := part("").PartSpec
+	var parts []hwsim.Part
+	var conns []string
+	io := make(map[*hwsim.PartSpec]bool)
+	for _, in := range spec.Inputs {
+		p := hwsim.Input(func() bool { return false })("out=" + in)
+		io[p.PartSpec] = true
+		parts = append(parts, p)
+		conns = append(conns, in+"="+in)
+	}
+	for _, out := range spec.Outputs {
+		p := hwsim.Output(func(bool) {})("in=" + out)
+		io[p.PartSpec] = true
+		parts = append(parts, p)
+		conns = append(conns, out+"="+out)
+	}
+	parts = append(parts, part(strings.Join(conns, ",")))
+	c, err := hwsim.NewCircuit(parts...)
+	if err != nil {
+		return nil, err
+	}
+	d := &Design{Part: part, Circuit: c}
+	for _, cp := range c.Components() {
+		if !io[cp.Spec] {
+			d.comps = append(d.comps, cp)
+		}
+	}
+	var ps []Problem
+	for _, r := range rules {
+		ps = append(ps, r(d)...)
+	}
+	return ps, nil
+}
+
+// Wiring reports the warnings returned by hwsim.Lint, like unused chip inputs
+// or unconnected part inputs.
+//
+func Wiring() Rule {
+	return func(d *Design) []Problem {
+		var ps []Problem
+		for _, w := range hwsim.Lint(d.Part) {
+			where := w.Chip
+			if w.Part >= 0 {
+				where += " part " + strconv.Itoa(w.Part) + " " + w.PartName + "(" + w.Conn + ")"
+			}
+			ps = append(ps, Problem{"wiring", where, w.Err.Error()})
+		}
+		return ps
+	}
+}
+
+// OnlyParts reports components that are not in the given list of part names.
+// Names are PartSpec names, like "NAND" or "DFF". It is typically used to
+// enforce gate-level only designs and reject custom Go parts.
+//
+func OnlyParts(names ...string) Rule {
+	ok := make(map[string]bool, len(names))
+	for _, n := range names {
+		ok[n] = true
+	}
+	return func(d *Design) []Problem {
+		var ps []Problem
+		for _, cp := range d.comps {
+			if !ok[cp.Spec.Name] {
+				ps = append(ps, Problem{"only-parts", cp.Name, "part " + cp.Spec.Name + " not allowed"})
+			}
+		}
+		return ps
+	}
+}
+
+// NoClk reports components that use the Clk signal directly, except
+// sequential components (those that implement hwsim.PostUpdater) and the
+// parts listed in allow.
+//
+func NoClk(allow ...string) Rule {
+	ok := make(map[string]bool, len(allow))
+	for _, n := range allow {
+		ok[n] = true
+	}
+	return func(d *Design) []Problem {
+		var ps []Problem
+		clk := d.Circuit.Wire(hwsim.Clk)
+		for _, cp := range d.comps {
+			if _, seq := cp.Updater.(hwsim.PostUpdater); seq || ok[cp.Spec.Name] {
+				continue
+			}
+			for i, w := range cp.Inputs {
+				if w == clk {
+					ps = append(ps, Problem{"no-clk", cp.Name, "input " + cp.Spec.Inputs[i] + " connected to " + hwsim.Clk})
+				}
+			}
+		}
+		return ps
+	}
+}
+
+// MaxFanOut reports wires connected to more than n component inputs.
+// Constant wires are ignored.
+//
+func MaxFanOut(n int) Rule {
+	return func(d *Design) []Problem {
+		c := d.Circuit
+		cst := map[*hwsim.Wire]bool{
+			c.Wire(hwsim.False): true,
+			c.Wire(hwsim.True):  true,
+			c.Wire(hwsim.Clk):   true,
+		}
+		fo := make(map[*hwsim.Wire]int)
+		for _, cp := range d.comps {
+			for _, w := range cp.Inputs {
+				if w != nil && !cst[w] {
+					fo[w]++
+				}
+			}
+		}
+		var ps []Problem
+		for w, k := range fo {
+			if k > n {
+				ps = append(ps, Problem{"max-fanout", c.WireName(w), "fan-out of " + strconv.Itoa(k) + " exceeds " + strconv.Itoa(n)})
+			}
+		}
+		sort.Slice(ps, func(i, j int) bool { return ps[i].Where < ps[j].Where })
+		return ps
+	}
+}
+
+// MaxDepth reports chips nested more than n levels deep. The part under
+// inspection is at level 1.
+//
+func MaxDepth(n int) Rule {
+	return func(d *Design) []Problem {
+		var ps []Problem
+		seen := make(map[string]bool)
+		for _, cp := range d.comps {
+			path := strings.Split(cp.Name, ".")
+			if len(path)-1 <= n {
+				continue
+			}
+			chip := strings.Join(path[:n+1], ".")
+			if !seen[chip] {
+				seen[chip] = true
+				ps = append(ps, Problem{"max-depth", chip, "hierarchy depth exceeds " + strconv.Itoa(n)})
+			}
+		}
+		return ps
+	}
+}
+
+// BusNames reports buses of the part and its sub-chips whose name does not
+// match re. The pins of leaf components are not checked.
+//
+func BusNames(re *regexp.Regexp) Rule {
+	return func(d *Design) []Problem {
+		leaf := make(map[string]bool)
+		for _, cp := range d.comps {
+			leaf[cp.Name] = true
+		}
+		var ps []Problem
+		seen := make(map[string]bool)
+		for _, n := range d.Circuit.WireNames() {
+			i := strings.IndexByte(n, '[')
+			if i < 0 {
+				continue
+			}
+			bus := n[:i]
+			name := bus
+			if j := strings.LastIndexByte(bus, '.'); j >= 0 {
+				if leaf[bus[:j]] {
+					continue
+				}
+				name = bus[j+1:]
+			}
+			if !seen[bus] && !re.MatchString(name) {
+				seen[bus] = true
+				ps = append(ps, Problem{"bus-names", bus, "bus name does not match " + re.String()})
+			}
+		}
+		return ps
+	}
+}
@@ -0,0 +1,83 @@
+package hwlint_test
+
+import (
+	"regexp"
+	"testing"
+
+	hw "github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+	"github.com/db47h/hwsim/hwlint"
+)
+
+func chip(t *testing.T, name, in, out string, parts ...hw.Part) hw.NewPartFn {
+	t.Helper()
+	p, err := hw.Chip(name, in, out, parts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCheck(t *testing.T) {
+	xor := chip(t, "XOR", "a, b", "out",
+		hl.Nand("a=a, b=b, out=nab"),
+		hl.Nand("a=a, b=nab, out=o0"),
+		hl.Nand("a=nab, b=b, out=o1"),
+		hl.Nand("a=o0, b=o1, out=out"),
+	)
+	top := chip(t, "TOP", "inBus[2], sel, unused", "out",
+		xor("a=inBus[0], b=inBus[1], out=x"),
+		hl.And("a=x, b=clk, out=xc"),
+		hl.Mux("a=x, b=xc, sel=sel, out=out"),
+		hl.DFF("in=x, out=q"),
+		hl.Not("in=q, out=Q_BUS[0]"),
+		hl.Not("in=Q_BUS[0]"),
+	)
+
+	data := []struct {
+		name  string
+		rules []hwlint.Rule
+		exp   []string
+	}{
+		{"only-nand", []hwlint.Rule{hwlint.OnlyParts("NAND", "DFF")}, []string{
+			"only-parts: TOP.AND: part AND not allowed",
+			"only-parts: TOP.MUX: part MUX not allowed",
+			"only-parts: TOP.NOT#0: part NOT not allowed",
+			"only-parts: TOP.NOT#1: part NOT not allowed",
+		}},
+		{"no-clk", []hwlint.Rule{hwlint.NoClk()}, []string{
+			"no-clk: TOP.AND: input b connected to clk",
+		}},
+		{"no-clk-allowed", []hwlint.Rule{hwlint.NoClk("AND")}, nil},
+		{"fan-out", []hwlint.Rule{hwlint.MaxFanOut(2)}, []string{
+			"max-fanout: TOP.x: fan-out of 3 exceeds 2",
+		}},
+		{"depth", []hwlint.Rule{hwlint.MaxDepth(1)}, []string{
+			"max-depth: TOP.XOR: hierarchy depth exceeds 1",
+		}},
+		{"depth2", []hwlint.Rule{hwlint.MaxDepth(2)}, nil},
+		{"bus-names", []hwlint.Rule{hwlint.BusNames(regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`))}, []string{
+			"bus-names: TOP.Q_BUS: bus name does not match ^[a-z][a-zA-Z0-9]*$",
+		}},
+		{"wiring", []hwlint.Rule{hwlint.Wiring()}, []string{
+			"wiring: TOP: input unused not used",
+			`wiring: TOP part 5 NOT(in=Q_BUS[0]): all outputs unused`,
+		}},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			ps, err := hwlint.Check(top, d.rules...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ps) != len(d.exp) {
+				t.Fatalf("got %d problems, expected %d: %v", len(ps), len(d.exp), ps)
+			}
+			for i := range ps {
+				if s := ps[i].String(); s != d.exp[i] {
+					t.Errorf("got %q, expected %q", s, d.exp[i])
+				}
+			}
+		})
+	}
+}
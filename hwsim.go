@@ -4,6 +4,9 @@
 package hwsim
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/pkg/errors"
 )
 
@@ -101,13 +104,44 @@ type PartSpec struct {
 
 	// Mount function (see MountFn).
 	Mount MountFn
+
+	// Bitwise is an optional function that computes the outputs of a
+	// combinational part for 64 independent sets of input values at once. See
+	// BitwiseFn. It is used by Batch.
+	Bitwise BitwiseFn
+
+	// Delay is the propagation delay of the part in abstract time units, used
+	// in timed simulation mode (see Circuit.SetTimed). If zero, the part has
+	// a delay of 1.
+	Delay int
+
+	// Gates is the size of the part in NAND-equivalent gates, as reported by
+	// ResourceUsage. If zero, the size of Impl is used, if any. It is ignored
+	// for chips built with Chip.
+	Gates int
+
+	// Impl is an optional gate-level implementation of the part, usually a
+	// chip built with Chip that has the same inputs and outputs. It is not
+	// used for simulation, but by tools that need to analyze the logic of
+	// custom parts, like formal equivalence checking.
+	Impl NewPartFn
+
+	// AltImpls lists alternative gate-level implementations of the part, like
+	// versions built from other primitive gates than Impl. Flatten uses the
+	// one of Impl and AltImpls that needs the fewest primitive parts.
+	AltImpls []NewPartFn
+
+	chip *chip // set for chips built with Chip
 }
 
 // NewPart is a NewPartFn that wraps p with the given connections into a Part.
 //
 func (p *PartSpec) NewPart(connections string) Part {
-	ex, err := ParseConnections(connections)
+	ex, pos, err := parseConnections(connections)
 	if err != nil {
+		if we, ok := err.(*WiringError); ok {
+			we.PartName = p.Name
+		}
 		panic(err)
 	}
 	if p.Pinout == nil {
@@ -119,7 +153,7 @@ func (p *PartSpec) NewPart(connections string) Part {
 			p.Pinout[o] = o
 		}
 	}
-	return Part{p, ex}
+	return Part{PartSpec: p, Conns: ex, conn: connections, pos: pos}
 }
 
 // A NewPartFn is a function that takes a connection configuration and returns a
@@ -134,6 +168,23 @@ type NewPartFn func(c string) Part
 type Part struct {
 	*PartSpec
 	Conns []Connection
+
+	conn string    // original connection string
+	pos  []connPos // position of Conns in conn
+}
+
+// col returns the column of the part pin of the i-th connection in the
+// connection string, or that of the chip pin if cp is true. It returns 0 if
+// unknown.
+//
+func (p *Part) col(i int, cp bool) int {
+	if i < 0 || i >= len(p.pos) {
+		return 0
+	}
+	if cp {
+		return p.pos[i].cp
+	}
+	return p.pos[i].pp
 }
 
 // A Wrapper is a part that wraps together several other parts and has no
@@ -148,11 +199,22 @@ type Wrapper interface {
 // Circuit is a runnable circuit simulation.
 //
 type Circuit struct {
-	wires []*Wire
-	ups   []PostUpdater
-	size  int // # of updaters
-	ticks uint64
-	clk   bool
+	wires   []*Wire
+	ups     []PostUpdater
+	size    int // # of updaters
+	ticks   uint64
+	half    int64 // index of the half cycle being simulated
+	clk     bool
+	names   map[string]*Wire // hierarchical wire names
+	wname   map[*Wire]string // canonical wire names
+	state   []Stateful
+	comps   []*Component
+	spec    *PartSpec // spec of the top-level chip
+	prog    []step    // compiled program, nil if not compiled
+	workers []worker
+	timed   *timedSim
+	cov     *coverage
+	faults  map[*Wire]bool // stuck-at faults
 }
 
 // NewCircuit builds a new circuit simulation based on the given parts.
@@ -167,8 +229,11 @@ func NewCircuit(parts ...Part) (*Circuit, error) {
 		return nil, errors.Wrap(err, "failed to create chip wrapper")
 	}
 
-	c := new(Circuit)
-	c.wires = make([]*Wire, cstCount)
+	c := &Circuit{
+		wires: make([]*Wire, cstCount),
+		names: make(map[string]*Wire),
+		wname: make(map[*Wire]string),
+	}
 
 	inputFn := func(f func() bool) *Wire {
 		p := new(Wire)
@@ -180,8 +245,12 @@ func NewCircuit(parts ...Part) (*Circuit, error) {
 	c.wires[cstFalse] = inputFn(func() bool { return false })
 	c.wires[cstTrue] = inputFn(func() bool { return true })
 	c.wires[cstClk] = inputFn(func() bool { return c.clk })
+	for i, n := range cstPinNames {
+		c.nameWire(n, c.wires[i])
+	}
 
-	c.unwrap(wrap("").Mount(newSocket(c)))
+	c.spec = wrap("").PartSpec
+	c.unwrap(c.spec.Mount(newSocket(c, "")))
 
 	for i := range c.wires {
 		if c.wires[i].src == nil {
@@ -189,6 +258,8 @@ func NewCircuit(parts ...Part) (*Circuit, error) {
 		}
 	}
 
+	c.compile()
+
 	return c, nil
 }
 
@@ -202,6 +273,9 @@ func (c *Circuit) unwrap(u Updater) {
 		if t, ok := u.(PostUpdater); ok {
 			c.ups = append(c.ups, t)
 		}
+		if t, ok := u.(Stateful); ok {
+			c.state = append(c.state, t)
+		}
 	}
 }
 
@@ -213,6 +287,65 @@ func (c *Circuit) allocPin() *Wire {
 	return p
 }
 
+// nameWire registers name as a hierarchical name for wire w. The first name
+// registered for a wire is its canonical name. Temporary wire names used
+// internally by Chip are ignored.
+//
+func (c *Circuit) nameWire(name string, w *Wire) {
+	if i := strings.LastIndexByte(name, '.'); strings.HasPrefix(name[i+1:], "__") {
+		return
+	}
+	if _, ok := c.names[name]; ok {
+		return
+	}
+	c.names[name] = w
+	if _, ok := c.wname[w]; !ok {
+		c.wname[w] = name
+	}
+}
+
+// Wire returns the wire with the given hierarchical name, or nil if no such
+// wire exists.
+//
+// Wires are named after the pins and wires of the chips that they connect,
+// prefixed with the names of the part instances that contain them, separated
+// by dots. For example, in a circuit built as:
+//
+//	NewCircuit(
+//		xor("a=x, b=y, out=z"), // xor is built with Chip()
+//		...
+//	)
+//
+// the wire "z" is also known as "XOR.out", and the internal wire nandAB of
+// the xor chip as "XOR.nandAB". The output of the first NAND gate in the XOR
+// chip is "XOR.NAND#0.out": when a chip contains several parts with the same
+// name, the name of each instance is suffixed with '#' and its rank among
+// these parts.
+//
+func (c *Circuit) Wire(name string) *Wire {
+	return c.names[name]
+}
+
+// WireName returns the canonical name of wire w, that is the name used for w
+// at the highest level in the circuit's hierarchy, or an empty string if w
+// has no name.
+//
+func (c *Circuit) WireName(w *Wire) string {
+	return c.wname[w]
+}
+
+// WireNames returns the canonical names of all the named wires in the circuit,
+// sorted in lexical order.
+//
+func (c *Circuit) WireNames() []string {
+	names := make([]string, 0, len(c.wname))
+	for _, n := range c.wname {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Ticks returns the value of the step counter.
 //
 func (c *Circuit) Ticks() uint64 {
@@ -238,17 +371,67 @@ func (c *Circuit) Tock() {
 	}
 }
 
+// Eval updates the circuit again for the last half clock cycle, without
+// advancing the clock. This is useful to propagate input changes through
+// combinational logic. Clocked components behave as during the last call to
+// Tick or Tock: a DFF still works like a gated D latch and will latch its new
+// input if the last half cycle was a Tick.
+//
+// Before the first call to Tick, Eval updates the circuit as if Tock had just
+// been called.
+//
+func (c *Circuit) Eval() {
+	c.updateAt(!c.clk)
+	if c.cov != nil {
+		c.cov.sample(c.wires)
+	}
+}
+
 func (c *Circuit) update() {
+	c.updateAt(c.clk)
+	if c.cov != nil {
+		c.cov.sample(c.wires)
+	}
+	c.ticks++
+}
+
+func (c *Circuit) updateAt(clk bool) {
+	c.half = int64(c.ticks)
+	if clk != c.clk {
+		// Eval
+		c.half--
+	}
+	if c.timed != nil && c.faults == nil {
+		c.timed.update(clk)
+		for _, u := range c.ups {
+			u.PostUpdate(clk)
+		}
+		return
+	}
 	for _, w := range c.wires {
-		w.clk = !c.clk
+		w.clk = !clk
+	}
+	if c.faults != nil {
+		c.forceFaults(clk)
 	}
 	for _, u := range c.ups {
-		u.Update(c.clk)
+		u.Update(clk)
+	}
+	if c.prog != nil && c.faults == nil {
+		for i := 0; i < cstCount; i++ {
+			c.wires[i].Recv(clk)
+		}
+		if c.workers != nil {
+			c.updateParallel(clk)
+			return
+		}
+		for i := range c.prog {
+			c.prog[i].run(clk)
+		}
 	}
 	for _, u := range c.ups {
-		u.PostUpdate(c.clk)
+		u.PostUpdate(clk)
 	}
-	c.ticks++
 }
 
 // TickTock runs the simulation for a whole clock cycle.
@@ -258,6 +441,24 @@ func (c *Circuit) TickTock() {
 	c.Tock()
 }
 
+// A Component is a leaf part mounted in a circuit, that is a part that is not
+// a chip built with Chip.
+//
+type Component struct {
+	Name    string    // hierarchical instance name, like "XOR.NAND#0"
+	Spec    *PartSpec // part specification
+	Inputs  []*Wire   // wires connected to the input pins listed in Spec.Inputs
+	Outputs []*Wire   // wires connected to the output pins listed in Spec.Outputs
+	Updater Updater   // the Updater returned by Spec.Mount
+}
+
+// Components returns the leaf components of the circuit, in the order they
+// were mounted.
+//
+func (c *Circuit) Components() []*Component {
+	return c.comps
+}
+
 // ComponentCount returns the number of components in the circuit.
 //
 func (c *Circuit) ComponentCount() int {
@@ -270,6 +471,62 @@ func (c *Circuit) WireCount() int {
 	return len(c.wires)
 }
 
+// A Snapshot holds the state of a circuit at a given time. See
+// Circuit.Snapshot.
+//
+type Snapshot struct {
+	ticks  uint64
+	clk    bool
+	wires  []Wire
+	states []interface{}
+}
+
+// Snapshot saves the current state of the circuit: clock, wire values and
+// the state of components that implement Stateful.
+//
+func (c *Circuit) Snapshot() *Snapshot {
+	s := &Snapshot{
+		ticks:  c.ticks,
+		clk:    c.clk,
+		wires:  make([]Wire, len(c.wires)),
+		states: make([]interface{}, len(c.state)),
+	}
+	for i, w := range c.wires {
+		s.wires[i] = Wire{clk: w.clk, value: w.value}
+	}
+	for i, u := range c.state {
+		s.states[i] = u.State()
+	}
+	return s
+}
+
+// Restore restores the state of the circuit from a snapshot taken with
+// Snapshot. The snapshot must have been taken from the same circuit.
+//
+func (c *Circuit) Restore(s *Snapshot) {
+	if len(s.wires) != len(c.wires) || len(s.states) != len(c.state) {
+		panic("snapshot does not match circuit")
+	}
+	c.ticks, c.clk = s.ticks, s.clk
+	for i, w := range c.wires {
+		w.clk, w.value = s.wires[i].clk, s.wires[i].value
+	}
+	for i, u := range c.state {
+		u.SetState(s.states[i])
+	}
+}
+
+// Stateful is implemented by components that have an internal state, like
+// flip-flops or memories, so that it can be saved and restored with
+// Circuit.Snapshot and Circuit.Restore.
+//
+type Stateful interface {
+	// State returns a copy of the component's state.
+	State() interface{}
+	// SetState sets the component's state to a value returned by State.
+	SetState(state interface{})
+}
+
 // PostUpdater is implemented by Updaters that have side effects outside of a
 // circuit or that somehow drive the circuit. All sequential components must
 // implement PostUpdater.
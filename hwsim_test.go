@@ -3,6 +3,7 @@ package hwsim_test
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
@@ -81,7 +82,6 @@ func ExampleIO() {
 }
 
 // testLib is a test library of components built entirely of nands
-//
 type testLib struct {
 	nand hwsim.NewPartFn
 	not  hwsim.NewPartFn
@@ -120,6 +120,10 @@ func (d *dff) PostUpdate(clk bool) {
 	}
 }
 
+func (d *dff) State() interface{} { return d.v }
+
+func (d *dff) SetState(s interface{}) { d.v = s.(bool) }
+
 func newTestLib() *testLib {
 	tl := &testLib{
 		nand: (&hwsim.PartSpec{
@@ -340,3 +344,123 @@ func BenchmarkCircuit_update(b *testing.B) {
 	delta := time.Since(t)
 	b.Logf("%d components, %d wires. %d clock ticks in %v => %.2f Hz", c.ComponentCount(), c.WireCount(), c.Ticks()/2, delta, float64(c.Ticks()/2)/(float64(delta)/float64(time.Second)))
 }
+
+func TestCircuit_Wire(t *testing.T) {
+	var in, out bool
+	c, err := hwsim.NewCircuit(
+		hwsim.Input(func() bool { return in })("out=x"),
+		tl.xor("a=x, b=true, out=notX"),
+		hwsim.Output(func(v bool) { out = v })("in=notX"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in = true
+	c.TickTock()
+	if out {
+		t.Fatal("expected out = false")
+	}
+
+	names := []struct {
+		name, canon string
+		v           bool
+	}{
+		{"x", "x", true},
+		{"xor.a", "x", true},
+		{"xor.b", "true", true},
+		{"xor.out", "notX", false},
+		{"xor.nab", "xor.nab", false},
+		{"xor.NAND#0.out", "xor.nab", false},
+		{"xor.NAND#3.out", "notX", false},
+	}
+	for _, n := range names {
+		w := c.Wire(n.name)
+		if w == nil {
+			t.Errorf("wire %s not found", n.name)
+			continue
+		}
+		if cn := c.WireName(w); cn != n.canon {
+			t.Errorf("canonical name of %s: expected %s, got %s", n.name, n.canon, cn)
+		}
+		if w.Value() != n.v {
+			t.Errorf("%s = %v, expected %v", n.name, w.Value(), n.v)
+		}
+	}
+	if c.Wire("xor.NAND.out") != nil {
+		t.Error("found wire for ambiguous name xor.NAND.out")
+	}
+}
+
+func TestCircuit_Components(t *testing.T) {
+	c, err := hwsim.NewCircuit(
+		hwsim.Input(func() bool { return true })("out=x"),
+		tl.xor("a=x, b=true, out=notX"),
+		hwsim.Output(func(v bool) {})("in=notX"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, cp := range c.Components() {
+		names = append(names, cp.Name)
+	}
+	exp := "in xor.NAND#0 xor.NAND#1 xor.NAND#2 xor.NAND#3 out"
+	if s := strings.Join(names, " "); s != exp {
+		t.Fatalf("expected components %q, got %q", exp, s)
+	}
+	nand := c.Components()[4]
+	if nand.Inputs[0] != c.Wire("xor.NAND#1.out") || nand.Inputs[1] != c.Wire("xor.NAND#2.out") || nand.Outputs[0] != c.Wire("notX") {
+		t.Fatalf("invalid wiring for %s", nand.Name)
+	}
+}
+
+// Circuits with combinational loops are not compiled and must still
+// report the loop at runtime.
+//
+func TestCircuit_loop(t *testing.T) {
+	c, err := hwsim.NewCircuit(
+		tl.nand("a=true, b=x, out=y"),
+		tl.nand("a=true, b=y, out=x"),
+		hwsim.Output(func(v bool) {})("in=x"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "wiring loop") {
+			t.Fatalf("expected wiring loop panic, got %v", r)
+		}
+	}()
+	c.TickTock()
+}
+
+func TestCircuit_Snapshot(t *testing.T) {
+	var enable, tick bool
+	c, err := hwsim.NewCircuit(
+		hwsim.Input(func() bool { return enable })("out=enable"),
+		tl.nand("a=enable, b=dff, out=tick"),
+		tl.dff("in=tick, out=dff"),
+		hwsim.Output(func(out bool) { tick = out })("in=tick"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enable = true
+	c.TickTock()
+	s := c.Snapshot()
+	var trace []bool
+	for i := 0; i < 3; i++ {
+		c.TickTock()
+		trace = append(trace, tick)
+	}
+	c.Restore(s)
+	if c.Ticks() != 2 {
+		t.Fatalf("ticks = %d after restore, expected 2", c.Ticks())
+	}
+	for i := 0; i < 3; i++ {
+		c.TickTock()
+		if tick != trace[i] {
+			t.Fatalf("cycle %d: got %v, expected %v", i, tick, trace[i])
+		}
+	}
+}
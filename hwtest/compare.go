@@ -6,7 +6,9 @@
 package hwtest
 
 import (
+	"flag"
 	"fmt"
+	"math/bits"
 	"math/rand"
 	"sort"
 	"strconv"
@@ -15,6 +17,7 @@ import (
 	"time"
 
 	"github.com/db47h/hwsim"
+	"github.com/pkg/errors"
 )
 
 func connString(in, out []string) string {
@@ -76,116 +79,221 @@ func pinList(in []string) string {
 	return b.String()
 }
 
-func randBool() bool {
-	return rand.Int63()&(1<<62) != 0
-}
+var seedFlag = flag.Int64("hwtest.seed", 0, "seed for random testing in hwtest (0 means use the current time)")
 
-// ComparePart takes two parts and compares their outputs given the same inputs.
-// Both parts must have the same Input/Output interface.
+// Options configures ComparePartWithOptions and CompareSequential.
 //
-func ComparePart(t *testing.T, part1 hwsim.NewPartFn, part2 hwsim.NewPartFn) {
-	t.Helper()
+type Options struct {
+	// Seed is used to initialize the random number generator. If 0, the seed
+	// is taken from the -hwtest.seed test flag, or from the current time if
+	// the flag is not set. The seed is reported on failure and can be replayed
+	// with -hwtest.seed.
+	Seed int64
 
-	rand.Seed(time.Now().UnixNano())
+	// Iterations is the number of random input sets tested by
+	// ComparePartWithOptions (default 4096), or the number of random traces
+	// run by CompareSequential (default 100).
+	Iterations int
 
-	ps1, ps2 := part1(""), part1("")
-	conns := connString(ps1.Inputs, ps1.Outputs)
-	ps1, ps2 = part1(conns), part2(conns)
+	// MaxBits is the maximum number of inputs for which
+	// ComparePartWithOptions tests all possible input values instead of
+	// random ones (default 12).
+	MaxBits int
 
-	sort.Strings(ps1.Inputs)
-	sort.Strings(ps1.Outputs)
-	sort.Strings(ps2.Inputs)
-	sort.Strings(ps2.Outputs)
+	// Length is the number of clock cycles in the traces run by
+	// CompareSequential (default 32).
+	Length int
 
-	inputs := make([]bool, len(ps1.Inputs))
-	outputs := make([][2]bool, len(ps1.Outputs))
+	// Batch makes ComparePartWithOptions test combinational parts 64 sets of
+	// input values at a time with a hwsim.Batch, which is much faster for
+	// parts with a Bitwise function. Batch evaluation does not run clock
+	// cycles: sequential parts are tested with regular simulation.
+	Batch bool
+}
 
-	// build two wrappers with their own set of outputs
-	parts1 := []hwsim.Part{ps1}
-	for i, o := range ps1.Outputs {
-		n := i
-		parts1 = append(parts1, hwsim.Output(func(b bool) { outputs[n][0] = b })("in="+o))
+// withDefaults returns a copy of o where unset fields are set to their
+// default value.
+//
+func (o *Options) withDefaults(iter int) Options {
+	var r Options
+	if o != nil {
+		r = *o
 	}
-	parts2 := []hwsim.Part{ps2}
-	for i, o := range ps2.Outputs {
-		n := i
-		parts2 = append(parts2, hwsim.Output(func(b bool) { outputs[n][1] = b })("in="+o))
+	if r.Seed == 0 {
+		r.Seed = *seedFlag
 	}
-	w1, err := hwsim.Chip("wrapper1", pinList(ps1.Inputs), "", parts1...)
-	if err != nil {
-		t.Fatal(err)
+	if r.Seed == 0 {
+		r.Seed = time.Now().UnixNano()
 	}
-	w2, err := hwsim.Chip("wrapper2", pinList(ps2.Inputs), "", parts2...)
-	if err != nil {
-		t.Fatal(err)
+	if r.Iterations <= 0 {
+		r.Iterations = iter
+	}
+	if r.MaxBits <= 0 {
+		r.MaxBits = 12
+	}
+	if r.Length <= 0 {
+		r.Length = 32
+	}
+	return r
+}
+
+func randBool(r *rand.Rand) bool {
+	return r.Int63()&(1<<62) != 0
+}
+
+// A harness wraps two parts with the same Input/Output interface in
+// circuits where they share the same inputs.
+//
+type harness struct {
+	ins, outs []string
+	inputs    []bool
+	outputs   [][2]bool
+	w1, w2    hwsim.NewPartFn
+}
+
+func newHarness(part1 hwsim.NewPartFn, part2 hwsim.NewPartFn) (*harness, error) {
+	ps1, ps2 := part1(""), part2("")
+	h := &harness{
+		ins:  append([]string(nil), ps1.Inputs...),
+		outs: append([]string(nil), ps1.Outputs...),
 	}
+	sort.Strings(h.ins)
+	sort.Strings(h.outs)
 
 	// compare specs
-	if len(ps1.Inputs) != len(ps2.Inputs) {
-		t.Fatal("len(ps1.Inputs) != len(ps2.Inputs)")
+	ins2 := append([]string(nil), ps2.Inputs...)
+	outs2 := append([]string(nil), ps2.Outputs...)
+	sort.Strings(ins2)
+	sort.Strings(outs2)
+	if len(h.ins) != len(ins2) {
+		return nil, errors.New("len(ps1.Inputs) != len(ps2.Inputs)")
 	}
-	if len(ps1.Outputs) != len(ps2.Outputs) {
-		t.Fatal("len(ps1.Outputs) != len(ps2.Outputs)")
+	if len(h.outs) != len(outs2) {
+		return nil, errors.New("len(ps1.Outputs) != len(ps2.Outputs)")
 	}
-	for i := range ps1.Inputs {
-		if ps1.Inputs[i] != ps2.Inputs[i] {
-			t.Fatalf("ps1.Inputs[i] = %q != ps2.Inputs[i] = %q", ps1.Inputs[i], ps2.Inputs[i])
+	for i := range h.ins {
+		if h.ins[i] != ins2[i] {
+			return nil, errors.Errorf("ps1.Inputs[i] = %q != ps2.Inputs[i] = %q", h.ins[i], ins2[i])
 		}
 	}
-	for i := range ps1.Outputs {
-		if ps1.Outputs[i] != ps2.Outputs[i] {
-			t.Fatalf("ps1.Outputs[i] = %q != ps2.Outputs[i] = %q", ps1.Outputs[i], ps2.Outputs[i])
+	for i := range h.outs {
+		if h.outs[i] != outs2[i] {
+			return nil, errors.Errorf("ps1.Outputs[i] = %q != ps2.Outputs[i] = %q", h.outs[i], outs2[i])
 		}
 	}
 
+	h.inputs = make([]bool, len(h.ins))
+	h.outputs = make([][2]bool, len(h.outs))
+
+	// build two wrappers with their own set of outputs
+	conns := connString(h.ins, h.outs)
+	parts1 := []hwsim.Part{part1(conns)}
+	parts2 := []hwsim.Part{part2(conns)}
+	for i, o := range h.outs {
+		n := i
+		parts1 = append(parts1, hwsim.Output(func(b bool) { h.outputs[n][0] = b })("in="+o))
+		parts2 = append(parts2, hwsim.Output(func(b bool) { h.outputs[n][1] = b })("in="+o))
+	}
+	var err error
+	if h.w1, err = hwsim.Chip("wrapper1", pinList(h.ins), "", parts1...); err != nil {
+		return nil, err
+	}
+	if h.w2, err = hwsim.Chip("wrapper2", pinList(h.ins), "", parts2...); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// circuit returns a new circuit with both parts in their initial state.
+//
+func (h *harness) circuit() (*hwsim.Circuit, error) {
 	var parts []hwsim.Part
-	for i, n := range ps1.Inputs {
+	for i, n := range h.ins {
 		k := i
-		parts = append(parts, hwsim.Input(func() bool { return inputs[k] })("out="+n))
+		parts = append(parts, hwsim.Input(func() bool { return h.inputs[k] })("out="+n))
 	}
-	cstr := connString(ps1.Inputs, nil)
-	parts = append(parts, w1(cstr), w2(cstr))
+	cstr := connString(h.ins, nil)
+	parts = append(parts, h.w1(cstr), h.w2(cstr))
+	return hwsim.NewCircuit(parts...)
+}
 
-	c, err := hwsim.NewCircuit(parts...)
-	if err != nil {
-		t.Fatal(err)
+// mismatch returns the index of the first output that differs between both
+// parts, or -1 if all outputs match.
+//
+func (h *harness) mismatch() int {
+	for o, out := range h.outputs {
+		if out[0] != out[1] {
+			return o
+		}
 	}
+	return -1
+}
 
-	errString := func(oname string, ex, got bool) string {
-		var b strings.Builder
-		for i, n := range ps1.Inputs {
-			if b.Len() > 0 {
-				b.WriteString(", ")
-			}
-			b.WriteString(n)
-			b.WriteRune('=')
-			if inputs[i] {
-				b.WriteString("true")
-			} else {
-				b.WriteString("false")
-			}
+func (h *harness) inputString() string {
+	var b strings.Builder
+	for i, n := range h.ins {
+		if b.Len() > 0 {
+			b.WriteString(", ")
 		}
-		for i, n := range ps1.Outputs {
-			if b.Len() > 0 {
-				b.WriteString(", ")
-			}
-			b.WriteString(n)
-			b.WriteRune('=')
-			if outputs[i][0] {
-				b.WriteString("true")
-			} else {
-				b.WriteString("false")
-			}
+		b.WriteString(n)
+		b.WriteRune('=')
+		b.WriteString(strconv.FormatBool(h.inputs[i]))
+	}
+	return b.String()
+}
+
+func (h *harness) errString(o int) string {
+	var b strings.Builder
+	b.WriteString(h.inputString())
+	for i, n := range h.outs {
+		if b.Len() > 0 {
+			b.WriteString(", ")
 		}
-		return fmt.Sprintf("\nExpected %s => %s=%v\nGot %v", b.String(), oname, ex, got)
+		b.WriteString(n)
+		b.WriteRune('=')
+		b.WriteString(strconv.FormatBool(h.outputs[i][0]))
+	}
+	return fmt.Sprintf("\nExpected %s => %s=%v\nGot %v", b.String(), h.outs[o], h.outputs[o][0], h.outputs[o][1])
+}
+
+// ComparePart takes two parts and compares their outputs given the same inputs.
+// Both parts must have the same Input/Output interface.
+//
+// It is equivalent to ComparePartWithOptions(t, part1, part2, nil).
+//
+func ComparePart(t *testing.T, part1 hwsim.NewPartFn, part2 hwsim.NewPartFn) {
+	t.Helper()
+	ComparePartWithOptions(t, part1, part2, nil)
+}
+
+// ComparePartWithOptions takes two parts and compares their outputs given the
+// same inputs. Both parts must have the same Input/Output interface.
+//
+// If the parts have at most opts.MaxBits inputs, all possible input values are
+// tested. Otherwise, opts.Iterations random input sets are tested. A nil opts
+// is equivalent to a zero Options.
+//
+// If opts.Batch is set, combinational parts are tested 64 sets of input values
+// at a time with a hwsim.Batch.
+//
+func ComparePartWithOptions(t *testing.T, part1 hwsim.NewPartFn, part2 hwsim.NewPartFn, opts *Options) {
+	t.Helper()
+
+	opt := opts.withDefaults(1 << 12)
+	h, err := newHarness(part1, part2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := h.circuit()
+	if err != nil {
+		t.Fatal(err)
 	}
+	inputs := h.inputs
 
 	// try all 0
 	c.TickTock()
-	for o, out := range outputs {
-		if out[0] != out[1] {
-			t.Fatal(errString(ps1.Outputs[o], out[0], out[1]))
-		}
+	if o := h.mismatch(); o >= 0 {
+		t.Fatal(h.errString(o))
 	}
 
 	// try all 1
@@ -193,53 +301,251 @@ func ComparePart(t *testing.T, part1 hwsim.NewPartFn, part2 hwsim.NewPartFn) {
 		inputs[in] = true
 	}
 	c.TickTock()
-	for o, out := range outputs {
-		if out[0] != out[1] {
-			t.Fatal(errString(ps1.Outputs[o], out[0], out[1]))
+	if o := h.mismatch(); o >= 0 {
+		t.Fatal(h.errString(o))
+	}
+
+	if opt.Batch {
+		if b, ins, outs := h.batch(c); b != nil {
+			h.compareBatch(t, b, ins, outs, &opt)
+			return
 		}
 	}
 
-	iter := len(ps1.Inputs)
-	const maxBits = 12
-	if iter > maxBits {
-		iter = 1 << maxBits
+	if len(inputs) > opt.MaxBits {
 		// random testing
-		for i := 0; i < iter; i++ {
+		rnd := rand.New(rand.NewSource(opt.Seed))
+		for i := 0; i < opt.Iterations; i++ {
 			for in := range inputs {
-				inputs[in] = randBool()
+				inputs[in] = randBool(rnd)
 			}
 			c.Tick()
-			for o, out := range outputs {
-				if out[0] != out[1] {
-					t.Fatal(errString(ps1.Outputs[o], out[0], out[1]))
-				}
+			if o := h.mismatch(); o >= 0 {
+				t.Fatal(h.errString(o) + seedString(opt.Seed))
 			}
 			c.Tock()
-			for o, out := range outputs {
-				if out[0] != out[1] {
-					t.Fatal(errString(ps1.Outputs[o], out[0], out[1]))
-				}
+			if o := h.mismatch(); o >= 0 {
+				t.Fatal(h.errString(o) + seedString(opt.Seed))
 			}
 		}
 	} else {
 		// try all inputs
-		iter = 1 << uint(iter)
+		iter := 1 << uint(len(inputs))
 		for i := 0; i < iter; i++ {
 			for in := range inputs {
 				inputs[in] = i&(1<<uint(in)) != 0
 			}
 			c.Tick()
-			for o, out := range outputs {
-				if out[0] != out[1] {
-					t.Fatal(errString(ps1.Outputs[o], out[0], out[1]))
-				}
+			if o := h.mismatch(); o >= 0 {
+				t.Fatal(h.errString(o))
 			}
 			c.Tock()
-			for o, out := range outputs {
-				if out[0] != out[1] {
-					t.Fatal(errString(ps1.Outputs[o], out[0], out[1]))
+			if o := h.mismatch(); o >= 0 {
+				t.Fatal(h.errString(o))
+			}
+		}
+	}
+}
+
+// batch returns a Batch for a circuit returned by h.circuit, along with its
+// input wires and the output wires of both parts, or nil if the parts are not
+// combinational.
+//
+func (h *harness) batch(c *hwsim.Circuit) (*hwsim.Batch, []*hwsim.Wire, [][2]*hwsim.Wire) {
+	ins := make([]*hwsim.Wire, len(h.ins))
+	for i, n := range h.ins {
+		if ins[i] = c.Wire(n); ins[i] == nil {
+			return nil, nil, nil
+		}
+	}
+	outs := make([][2]*hwsim.Wire, len(h.outs))
+	for i, n := range h.outs {
+		outs[i] = [2]*hwsim.Wire{c.Wire("wrapper1." + n), c.Wire("wrapper2." + n)}
+		if outs[i][0] == nil || outs[i][1] == nil {
+			return nil, nil, nil
+		}
+	}
+	b, err := hwsim.NewBatch(c, ins...)
+	if err != nil {
+		return nil, nil, nil
+	}
+	return b, ins, outs
+}
+
+// compareBatch compares the outputs of both parts 64 sets of input values at
+// a time.
+//
+func (h *harness) compareBatch(t *testing.T, b *hwsim.Batch, ins []*hwsim.Wire, outs [][2]*hwsim.Wire, opt *Options) {
+	t.Helper()
+
+	var rnd *rand.Rand
+	iter := 1 << uint(len(ins))
+	if len(ins) > opt.MaxBits {
+		rnd = rand.New(rand.NewSource(opt.Seed))
+		iter = opt.Iterations
+	}
+	lanes := make([]uint64, len(ins))
+	for base := 0; base < iter; base += hwsim.Lanes {
+		for i, w := range ins {
+			if rnd != nil {
+				lanes[i] = rnd.Uint64()
+			} else {
+				lanes[i] = 0
+				for l := 0; l < hwsim.Lanes; l++ {
+					if (base+l)&(1<<uint(i)) != 0 {
+						lanes[i] |= 1 << uint(l)
+					}
 				}
 			}
+			b.Set(w, lanes[i])
+		}
+		b.Eval()
+		var diff uint64
+		for _, o := range outs {
+			diff |= b.Get(o[0]) ^ b.Get(o[1])
+		}
+		if diff == 0 {
+			continue
+		}
+		// replay the first failing lane in a new circuit
+		lane := uint(bits.TrailingZeros64(diff))
+		for i, v := range lanes {
+			h.inputs[i] = v&(1<<lane) != 0
+		}
+		c, err := h.circuit()
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.TickTock()
+		// if the outputs do not differ in the replay, some parts have a
+		// Bitwise function that does not match their regular implementation.
+		msg := "\nOutputs differ in bit-parallel simulation only for " + h.inputString()
+		if o := h.mismatch(); o >= 0 {
+			msg = h.errString(o)
+		}
+		if rnd != nil {
+			msg += seedString(opt.Seed)
+		}
+		t.Fatal(msg)
+	}
+}
+
+func seedString(seed int64) string {
+	return fmt.Sprintf("\nSeed %d (replay with -hwtest.seed=%d)", seed, seed)
+}
+
+// run runs a trace of input values from the initial state of both parts. If
+// the outputs differ, it returns the index of the failing clock cycle, the
+// failing half cycle (false during Tick, true during Tock) and the index of
+// the first differing output. Otherwise it returns a cycle index of -1.
+//
+func (h *harness) run(trace [][]bool) (cycle int, clk bool, o int, err error) {
+	c, err := h.circuit()
+	if err != nil {
+		return -1, false, -1, err
+	}
+	for i, in := range trace {
+		copy(h.inputs, in)
+		c.Tick()
+		if o = h.mismatch(); o >= 0 {
+			return i, false, o, nil
+		}
+		c.Tock()
+		if o = h.mismatch(); o >= 0 {
+			return i, true, o, nil
+		}
+	}
+	return -1, false, -1, nil
+}
+
+// shrink removes clock cycles from a failing trace until no single cycle can
+// be removed without making it pass.
+//
+func (h *harness) shrink(trace [][]bool) ([][]bool, error) {
+	for changed := true; changed; {
+		changed = false
+		for i := len(trace) - 2; i >= 0; i-- {
+			if i >= len(trace)-1 {
+				continue
+			}
+			cand := append(append([][]bool(nil), trace[:i]...), trace[i+1:]...)
+			cycle, _, _, err := h.run(cand)
+			if err != nil {
+				return nil, err
+			}
+			if cycle >= 0 {
+				trace, changed = cand[:cycle+1], true
+			}
 		}
 	}
+	return trace, nil
+}
+
+// CompareSequential takes two sequential parts and compares their outputs
+// given the same sequences of inputs.
+//
+// Both parts are run from their initial state through opts.Iterations random
+// input traces of opts.Length clock cycles, and the outputs are compared after
+// every half clock cycle. On failure, the shortest failing trace found is
+// reported along with the seed, so that it can be reproduced. A nil opts is
+// equivalent to a zero Options.
+//
+// Both parts must have the same Input/Output interface.
+//
+func CompareSequential(t *testing.T, part1 hwsim.NewPartFn, part2 hwsim.NewPartFn, opts *Options) {
+	t.Helper()
+
+	opt := opts.withDefaults(100)
+	h, err := newHarness(part1, part2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(opt.Seed))
+	for n := 0; n < opt.Iterations; n++ {
+		trace := make([][]bool, opt.Length)
+		for i := range trace {
+			trace[i] = make([]bool, len(h.ins))
+			for in := range trace[i] {
+				trace[i][in] = randBool(rnd)
+			}
+		}
+		cycle, _, _, err := h.run(trace)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cycle < 0 {
+			continue
+		}
+		if trace, err = h.shrink(trace[:cycle+1]); err != nil {
+			t.Fatal(err)
+		}
+		// replay the shortest trace to get the failing outputs
+		_, clk, o, _ := h.run(trace)
+		t.Fatal(h.traceString(trace, clk, o) + seedString(opt.Seed))
+	}
+}
+
+func (h *harness) traceString(trace [][]bool, clk bool, o int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nFailing trace of %d clock cycles:", len(trace))
+	for i, in := range trace {
+		fmt.Fprintf(&b, "\n%4d:", i+1)
+		for j, n := range h.ins {
+			b.WriteRune(' ')
+			b.WriteString(n)
+			b.WriteRune('=')
+			if in[j] {
+				b.WriteRune('1')
+			} else {
+				b.WriteRune('0')
+			}
+		}
+	}
+	half := "tick"
+	if clk {
+		half = "tock"
+	}
+	fmt.Fprintf(&b, "\nExpected %s=%v at %s of cycle %d\nGot %v", h.outs[o], h.outputs[o][0], half, len(trace), h.outputs[o][1])
+	return b.String()
 }
@@ -1,9 +1,14 @@
 package hwtest_test
 
 import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
 	"testing"
 
 	hw "github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
 	"github.com/db47h/hwsim/hwtest"
 )
 
@@ -38,4 +43,139 @@ func TestComparePart(t *testing.T) {
 		t.Fatal(err)
 	}
 	hwtest.ComparePart(t, or.NewPart, or2)
+	// force random testing
+	hwtest.ComparePartWithOptions(t, or.NewPart, or2, &hwtest.Options{MaxBits: 1, Iterations: 16})
+	// bit-parallel evaluation
+	hwtest.ComparePartWithOptions(t, hl.Or, or2, &hwtest.Options{Batch: true})
+	hwtest.ComparePartWithOptions(t, hl.Or, or2, &hwtest.Options{Batch: true, MaxBits: 1, Iterations: 256})
+}
+
+type bitReg struct {
+	in, load, out *hw.Wire
+	v             bool
+}
+
+func (b *bitReg) Update(clk bool) { b.out.Send(clk, b.v) }
+
+func (b *bitReg) PostUpdate(clk bool) {
+	in, load := b.in.Recv(clk), b.load.Recv(clk)
+	if !clk && load {
+		b.v = in
+	}
+}
+
+var bit = &hw.PartSpec{
+	Name:    "bit",
+	Inputs:  []string{"in", "load"},
+	Outputs: []string{"out"},
+	Mount: func(s *hw.Socket) hw.Updater {
+		return &bitReg{in: s.Wire("in"), load: s.Wire("load"), out: s.Wire("out")}
+	}}
+
+func TestCompareSequential(t *testing.T) {
+	bit2, err := hw.Chip("custom_bit", "in, load", "out",
+		hl.Mux("a=out, b=in, sel=load, out=d"),
+		hl.DFF("in=d, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareSequential(t, bit.NewPart, bit2, &hwtest.Options{Seed: 42, Length: 16})
+}
+
+// failing runs the test name in a new process, with the environment variable
+// HWTEST_FAILING set, and returns its output. The test must fail.
+//
+func failing(t *testing.T, name string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^" + name + "$"}, args...)...)
+	cmd.Env = append(os.Environ(), "HWTEST_FAILING=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("%s passed:\n%s", name, out)
+	}
+	return string(out)
+}
+
+func TestCompareSequential_mismatch(t *testing.T) {
+	// forgets its value when not loaded
+	bad, err := hw.Chip("bad_bit", "in, load", "out",
+		hl.And("a=in, b=load, out=d"),
+		hl.DFF("in=d, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("HWTEST_FAILING") != "" {
+		hwtest.CompareSequential(t, bit.NewPart, bad, &hwtest.Options{Seed: 42, Length: 16})
+		return
+	}
+	// the first failing trace has 11 cycles, shrunk to the shortest one.
+	out := failing(t, "TestCompareSequential_mismatch")
+	exp := `
+Failing trace of 2 clock cycles:
+1: in=1 load=1
+2: in=0 load=0
+Expected out=true at tock of cycle 2
+Got false
+Seed 42 (replay with -hwtest.seed=42)
+`
+	if !strings.Contains(trimLines(out), exp) {
+		t.Fatalf("expected output to contain:%s\ngot:\n%s", exp, out)
+	}
+}
+
+// trimLines removes the leading and trailing spaces of every line in s.
+//
+func trimLines(s string) string {
+	l := strings.Split(s, "\n")
+	for i := range l {
+		l[i] = strings.TrimSpace(l[i])
+	}
+	return strings.Join(l, "\n")
+}
+
+func TestOptions_seed(t *testing.T) {
+	// the parts differ for 1/8th of the input values, but not for all 0s
+	// or all 1s.
+	parity := []hw.Part{
+		hl.Xor("a=a, b=b, out=x0"),
+		hl.Xor("a=c, b=d, out=x1"),
+		hl.Xor("a=e, b=f, out=x2"),
+		hl.Xor("a=g, b=h, out=x3"),
+		hl.Xor("a=x0, b=x1, out=y0"),
+		hl.Xor("a=x2, b=x3, out=y1"),
+	}
+	ref, err := hw.Chip("P", "a, b, c, d, e, f, g, h", "out",
+		append(parity, hl.Xor("a=y0, b=y1, out=out"))...,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, err := hw.Chip("P", "a, b, c, d, e, f, g, h", "out",
+		append(parity[:len(parity):len(parity)],
+			hl.Xor("a=y0, b=y1, out=p"),
+			hl.Or("a=a, b=b, out=ab"),
+			hl.And("a=p, b=ab, out=out"),
+		)...,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("HWTEST_FAILING") != "" {
+		hwtest.ComparePartWithOptions(t, ref, bad, &hwtest.Options{MaxBits: 4})
+		return
+	}
+
+	re := regexp.MustCompile(`(?s)Expected .*\nSeed (-?\d+) \(replay with -hwtest.seed=(-?\d+)\)`)
+	out := trimLines(failing(t, "TestOptions_seed"))
+	m := re.FindStringSubmatch(out)
+	if m == nil || m[1] != m[2] {
+		t.Fatalf("seed not reported:\n%s", out)
+	}
+	// same failing input values
+	replay := trimLines(failing(t, "TestOptions_seed", "-hwtest.seed="+m[1]))
+	if r := re.FindStringSubmatch(replay); r == nil || r[0] != m[0] {
+		t.Fatalf("seed %s: got failure:\n%s\nexpected:\n%s", m[1], replay, out)
+	}
 }
@@ -0,0 +1,446 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/db47h/hwsim"
+	"github.com/db47h/hwsim/internal/sat"
+	"github.com/pkg/errors"
+)
+
+// maxTableInputs is the maximum number of inputs of a part whose truth table
+// can be sampled by Equivalent.
+//
+const maxTableInputs = 10
+
+// wire indices of constant wires in a net.
+//
+const (
+	netFalse = iota
+	netTrue
+	netClk
+	netCstCount
+)
+
+// A net is the gate-level netlist of a combinational part.
+//
+type net struct {
+	ins, outs []int // wires connected to the part's inputs and outputs
+	gates     []netGate
+	wires     int
+}
+
+// A netGate is a leaf component in a net. Gates are sorted in topological
+// order.
+//
+type netGate struct {
+	spec      *hwsim.PartSpec
+	name      string
+	ins, outs []int
+}
+
+// newNet builds the netlist for the given part. The ins and outs slices of
+// the returned net follow the order of the pin names in ins and outs.
+//
+func newNet(part hwsim.NewPartFn, ins, outs []string) (*net, error) {
+	var parts []hwsim.Part
+	var conns []string
+	inSpecs := make(map[*hwsim.PartSpec]int)
+	outSpecs := make(map[*hwsim.PartSpec]int)
+	for i, k := range ins {
+		w := "i" + strconv.Itoa(i)
+		p := hwsim.Input(func() bool { return false })("out=" + w)
+		inSpecs[p.PartSpec] = i
+		parts = append(parts, p)
+		conns = append(conns, k+"="+w)
+	}
+	for i, k := range outs {
+		w := "o" + strconv.Itoa(i)
+		p := hwsim.Output(func(bool) {})("in=" + w)
+		outSpecs[p.PartSpec] = i
+		parts = append(parts, p)
+		conns = append(conns, k+"="+w)
+	}
+	parts = append(parts, part(strings.Join(conns, ",")))
+	c, err := hwsim.NewCircuit(parts...)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &net{ins: make([]int, len(ins)), outs: make([]int, len(outs)), wires: netCstCount}
+	widx := map[*hwsim.Wire]int{
+		c.Wire(hwsim.False): netFalse,
+		c.Wire(hwsim.True):  netTrue,
+		c.Wire(hwsim.Clk):   netClk,
+	}
+	idx := func(w *hwsim.Wire) int {
+		if w == nil {
+			return netFalse
+		}
+		i, ok := widx[w]
+		if !ok {
+			i = n.wires
+			widx[w] = i
+			n.wires++
+		}
+		return i
+	}
+	var gates []netGate
+	for _, cp := range c.Components() {
+		if i, ok := inSpecs[cp.Spec]; ok {
+			n.ins[i] = idx(cp.Outputs[0])
+			continue
+		}
+		if i, ok := outSpecs[cp.Spec]; ok {
+			n.outs[i] = idx(cp.Inputs[0])
+			continue
+		}
+		if _, ok := cp.Updater.(hwsim.PostUpdater); ok {
+			return nil, errors.Errorf("%s: sequential component %s not supported", cp.Name, cp.Spec.Name)
+		}
+		g := netGate{spec: cp.Spec, name: cp.Name, ins: make([]int, len(cp.Inputs)), outs: make([]int, len(cp.Outputs))}
+		for i, w := range cp.Inputs {
+			if g.ins[i] = idx(w); g.ins[i] == netClk {
+				return nil, errors.Errorf("%s: component %s uses the clock signal", cp.Name, cp.Spec.Name)
+			}
+		}
+		for i, w := range cp.Outputs {
+			g.outs[i] = idx(w)
+		}
+		gates = append(gates, g)
+	}
+
+	// topological sort
+	driver := make([]int, n.wires)
+	for i := range driver {
+		driver[i] = -1
+	}
+	for i, g := range gates {
+		for _, w := range g.outs {
+			driver[w] = i
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(gates))
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visiting:
+			return errors.Errorf("%s: combinational loop", gates[i].name)
+		case done:
+			return nil
+		}
+		state[i] = visiting
+		for _, w := range gates[i].ins {
+			if d := driver[w]; d >= 0 {
+				if err := visit(d); err != nil {
+					return err
+				}
+			}
+		}
+		state[i] = done
+		n.gates = append(n.gates, gates[i])
+		return nil
+	}
+	for i := range gates {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// A truthTable holds the output values of a part for all possible input
+// values.
+//
+type truthTable [][]bool
+
+func sampleTable(spec *hwsim.PartSpec) (truthTable, error) {
+	if len(spec.Inputs) > maxTableInputs {
+		return nil, errors.Errorf("part %s has more than %d inputs and no gate-level implementation (see PartSpec.Impl)", spec.Name, maxTableInputs)
+	}
+	var row uint
+	out := make([]bool, len(spec.Outputs))
+	var parts []hwsim.Part
+	var conns []string
+	outSpecs := make(map[*hwsim.PartSpec]bool)
+	for i, k := range spec.Inputs {
+		bit := uint(i)
+		w := "i" + strconv.Itoa(i)
+		parts = append(parts, hwsim.Input(func() bool { return row&(1<<bit) != 0 })("out="+w))
+		conns = append(conns, k+"="+w)
+	}
+	for i, k := range spec.Outputs {
+		o := &out[i]
+		w := "o" + strconv.Itoa(i)
+		p := hwsim.Output(func(v bool) { *o = v })("in=" + w)
+		outSpecs[p.PartSpec] = true
+		parts = append(parts, p)
+		conns = append(conns, k+"="+w)
+	}
+	parts = append(parts, spec.NewPart(strings.Join(conns, ",")))
+	c, err := hwsim.NewCircuit(parts...)
+	if err != nil {
+		return nil, err
+	}
+	for _, cp := range c.Components() {
+		if _, ok := cp.Updater.(hwsim.PostUpdater); ok && !outSpecs[cp.Spec] {
+			return nil, errors.Errorf("sequential component %s not supported", cp.Spec.Name)
+		}
+	}
+	t := make(truthTable, 1<<uint(len(spec.Inputs)))
+	for row = 0; row < uint(len(t)); row++ {
+		c.TickTock()
+		t[row] = append([]bool(nil), out...)
+	}
+	return t, nil
+}
+
+// An encoder encodes nets into CNF clauses.
+//
+type encoder struct {
+	s      *sat.Solver
+	f      int // literal always false
+	nets   map[*hwsim.PartSpec]*net
+	tables map[*hwsim.PartSpec]truthTable
+	hash   map[string][]int // structural hashing of gates
+}
+
+func newEncoder() *encoder {
+	e := &encoder{
+		s:      sat.New(),
+		nets:   make(map[*hwsim.PartSpec]*net),
+		tables: make(map[*hwsim.PartSpec]truthTable),
+		hash:   make(map[string][]int),
+	}
+	e.f = e.s.NewVar()
+	e.s.AddClause(-e.f)
+	return e
+}
+
+// encode encodes net n with the given input literals and returns the literals
+// of its outputs.
+//
+func (e *encoder) encode(n *net, in []int) ([]int, error) {
+	lits := make([]int, n.wires)
+	lits[netFalse], lits[netTrue] = e.f, -e.f
+	for i, w := range n.ins {
+		lits[w] = in[i]
+	}
+	for _, g := range n.gates {
+		gin := make([]int, len(g.ins))
+		for i, w := range g.ins {
+			gin[i] = lits[w]
+		}
+		gout, err := e.gate(g.spec, gin)
+		if err != nil {
+			return nil, errors.Wrap(err, g.name)
+		}
+		for i, w := range g.outs {
+			lits[w] = gout[i]
+		}
+	}
+	out := make([]int, len(n.outs))
+	for i, w := range n.outs {
+		if out[i] = lits[w]; out[i] == 0 {
+			// output driven by an unconnected input pin
+			out[i] = e.f
+		}
+	}
+	return out, nil
+}
+
+// gate encodes a leaf component. Components with a gate-level implementation
+// are expanded, others are encoded from their truth table.
+//
+func (e *encoder) gate(spec *hwsim.PartSpec, in []int) ([]int, error) {
+	key := fmt.Sprintf("%p%v", spec, in)
+	if out, ok := e.hash[key]; ok {
+		return out, nil
+	}
+	var out []int
+	if spec.Impl != nil {
+		n, ok := e.nets[spec]
+		if !ok {
+			var err error
+			if n, err = newNet(spec.Impl, spec.Inputs, spec.Outputs); err != nil {
+				return nil, errors.Wrap(err, "implementation of "+spec.Name)
+			}
+			e.nets[spec] = n
+		}
+		var err error
+		if out, err = e.encode(n, in); err != nil {
+			return nil, err
+		}
+	} else {
+		t, ok := e.tables[spec]
+		if !ok {
+			var err error
+			if t, err = sampleTable(spec); err != nil {
+				return nil, err
+			}
+			e.tables[spec] = t
+		}
+		out = e.table(t, in)
+	}
+	e.hash[key] = out
+	return out, nil
+}
+
+// table encodes a truth table with the given input literals.
+//
+func (e *encoder) table(t truthTable, in []int) []int {
+	out := make([]int, len(t[0]))
+	for o := range out {
+		// constant outputs
+		cst := true
+		for _, r := range t {
+			if r[o] != t[0][o] {
+				cst = false
+				break
+			}
+		}
+		if cst {
+			out[o] = e.f
+			if t[0][o] {
+				out[o] = -e.f
+			}
+			continue
+		}
+		y := e.s.NewVar()
+		out[o] = y
+		c := make([]int, len(in)+1)
+		for row, r := range t {
+			for i, l := range in {
+				if row&(1<<uint(i)) != 0 {
+					c[i] = -l
+				} else {
+					c[i] = l
+				}
+			}
+			if r[o] {
+				c[len(in)] = y
+			} else {
+				c[len(in)] = -y
+			}
+			e.s.AddClause(c...)
+		}
+	}
+	return out
+}
+
+// Equivalent checks formally that two combinational parts compute the same
+// function. Both parts must have the same Input/Output interface.
+//
+// The parts are converted to a set of CNF clauses checked by a SAT solver.
+// Components are encoded either from their gate-level implementation if their
+// PartSpec has an Impl, or from their truth table, which is sampled by
+// simulation for components that have at most 10 inputs.
+//
+// If both parts are equivalent, Equivalent returns nil. Otherwise, it returns a
+// counterexample: input values, by pin name, for which the outputs of both
+// parts differ.
+//
+func Equivalent(part1, part2 hwsim.NewPartFn) (map[string]bool, error) {
+	h, err := newHarness(part1, part2)
+	if err != nil {
+		return nil, err
+	}
+	n1, err := newNet(part1, h.ins, h.outs)
+	if err != nil {
+		return nil, err
+	}
+	n2, err := newNet(part2, h.ins, h.outs)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newEncoder()
+	in := make([]int, len(h.ins))
+	for i := range in {
+		in[i] = e.s.NewVar()
+	}
+	o1, err := e.encode(n1, in)
+	if err != nil {
+		return nil, err
+	}
+	o2, err := e.encode(n2, in)
+	if err != nil {
+		return nil, err
+	}
+
+	// miter
+	var diff []int
+	for i := range o1 {
+		if o1[i] == o2[i] {
+			continue
+		}
+		d := e.s.NewVar()
+		e.s.AddClause(-d, o1[i], o2[i])
+		e.s.AddClause(-d, -o1[i], -o2[i])
+		diff = append(diff, d)
+	}
+	e.s.AddClause(diff...)
+	if len(diff) == 0 || !e.s.Solve() {
+		return nil, nil
+	}
+	cex := make(map[string]bool, len(in))
+	for i, k := range h.ins {
+		cex[k] = e.s.Value(in[i])
+	}
+	return cex, nil
+}
+
+// CompareFormal takes two combinational parts and checks formally that they
+// are equivalent with Equivalent. On failure, the counterexample is reported
+// with the outputs of both parts.
+//
+// Both parts must have the same Input/Output interface.
+//
+func CompareFormal(t *testing.T, part1 hwsim.NewPartFn, part2 hwsim.NewPartFn) {
+	t.Helper()
+
+	cex, err := Equivalent(part1, part2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cex == nil {
+		return
+	}
+	h, err := newHarness(part1, part2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := h.circuit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, k := range h.ins {
+		h.inputs[i] = cex[k]
+	}
+	c.TickTock()
+	if o := h.mismatch(); o >= 0 {
+		t.Fatal(h.errString(o))
+	}
+	keys := make([]string, 0, len(cex))
+	for k := range cex {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, cex[k])
+	}
+	t.Fatalf("parts are not equivalent for inputs%s", b.String())
+}
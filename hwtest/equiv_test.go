@@ -0,0 +1,138 @@
+package hwtest_test
+
+import (
+	"strconv"
+	"testing"
+
+	hw "github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+	"github.com/db47h/hwsim/hwtest"
+)
+
+func adder(t *testing.T, bits int, fa hw.NewPartFn, bug bool) hw.NewPartFn {
+	t.Helper()
+	var parts []hw.Part
+	for i := 0; i < bits; i++ {
+		is := strconv.Itoa(i)
+		ci := "c" + is
+		if i == 0 {
+			ci = "false"
+		}
+		bi := is
+		if bug && i == bits/2 {
+			bi = strconv.Itoa(i - 1)
+		}
+		co := ", carry=c" + strconv.Itoa(i+1)
+		if i == bits-1 {
+			co = ""
+		}
+		parts = append(parts, fa("a=a["+is+"], b=b["+bi+"], c="+ci+", sum=out["+is+"]"+co))
+	}
+	n := strconv.Itoa(bits)
+	add, err := hw.Chip("Add"+n, "a["+n+"], b["+n+"]", "out["+n+"]", parts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return add
+}
+
+func fullAdders(t *testing.T) (hw.NewPartFn, hw.NewPartFn) {
+	t.Helper()
+	fa1, err := hw.Chip("FullAdder", "a, b, c", "sum, carry",
+		hl.Xor("a=a, b=b, out=ab"),
+		hl.Xor("a=ab, b=c, out=sum"),
+		hl.And("a=a, b=b, out=c1"),
+		hl.And("a=ab, b=c, out=c2"),
+		hl.Or("a=c1, b=c2, out=carry"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xor, err := hw.Chip("XOR", "a, b", "out",
+		hl.Nand("a=a, b=b, out=nab"),
+		hl.Nand("a=a, b=nab, out=x"),
+		hl.Nand("a=nab, b=b, out=y"),
+		hl.Nand("a=x, b=y, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa2, err := hw.Chip("FullAdder", "a, b, c", "sum, carry",
+		xor("a=a, b=b, out=ab"),
+		xor("a=ab, b=c, out=sum"),
+		hl.Nand("a=a, b=b, out=n1"),
+		hl.Nand("a=ab, b=c, out=n2"),
+		hl.Nand("a=n1, b=n2, out=carry"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fa1, fa2
+}
+
+func TestCompareFormal(t *testing.T) {
+	fa1, fa2 := fullAdders(t)
+	hwtest.CompareFormal(t, adder(t, 32, fa1, false), adder(t, 32, fa2, false))
+
+	// Mux16 has a gate-level implementation
+	var parts []hw.Part
+	for i := 0; i < 16; i++ {
+		is := strconv.Itoa(i)
+		parts = append(parts, hl.Nand("a=a["+is+"], b=nsel, out=x"+is),
+			hl.Nand("a=b["+is+"], b=sel, out=y"+is),
+			hl.Nand("a=x"+is+", b=y"+is+", out=out["+is+"]"))
+	}
+	parts = append(parts, hl.Not("in=sel, out=nsel"))
+	mux16, err := hw.Chip("MUX16", "a[16], b[16], sel", "out[16]", parts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.CompareFormal(t, hl.MuxN(16), mux16)
+}
+
+func TestEquivalent(t *testing.T) {
+	fa1, fa2 := fullAdders(t)
+	add1, add2 := adder(t, 16, fa1, false), adder(t, 16, fa2, true)
+	cex, err := hwtest.Equivalent(add1, add2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cex == nil {
+		t.Fatal("no counterexample found")
+	}
+
+	// check the counterexample
+	var a, b, out1, out2 uint16
+	for i := uint(0); i < 16; i++ {
+		if cex["a["+strconv.Itoa(int(i))+"]"] {
+			a |= 1 << i
+		}
+		if cex["b["+strconv.Itoa(int(i))+"]"] {
+			b |= 1 << i
+		}
+	}
+	c, err := hw.NewCircuit(
+		hl.Input16(&a)("out=a"),
+		hl.Input16(&b)("out=b"),
+		add1("a=a, b=b, out=o1"),
+		add2("a=a, b=b, out=o2"),
+		hl.Output16(&out1)("in=o1"),
+		hl.Output16(&out2)("in=o2"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.TickTock()
+	if out1 == out2 {
+		t.Fatalf("invalid counterexample a=%d, b=%d: out=%d", a, b, out1)
+	}
+	if out1 != a+b {
+		t.Fatalf("%d + %d = %d, expected %d", a, b, out1, a+b)
+	}
+}
+
+func TestEquivalent_sequential(t *testing.T) {
+	if _, err := hwtest.Equivalent(bit.NewPart, bit.NewPart); err == nil {
+		t.Fatal("expected error for sequential part")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwtest
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/db47h/hwsim"
+)
+
+// A FaultReport is the result of a fault simulation. See FaultCoverage.
+//
+type FaultReport struct {
+	Faults []FaultResult
+}
+
+// FaultResult is the result of the simulation of a single fault.
+//
+type FaultResult struct {
+	hwsim.Fault
+	Detected bool
+	Line     int // line of the truth table where the fault was first detected
+}
+
+// Coverage returns the fault coverage, that is the ratio of detected faults
+// over the total number of faults.
+//
+func (r *FaultReport) Coverage() float64 {
+	if len(r.Faults) == 0 {
+		return 1
+	}
+	return float64(len(r.Faults)-len(r.Undetected())) / float64(len(r.Faults))
+}
+
+// Undetected returns the faults that were not detected.
+//
+func (r *FaultReport) Undetected() []hwsim.Fault {
+	var fs []hwsim.Fault
+	for _, f := range r.Faults {
+		if !f.Detected {
+			fs = append(fs, f.Fault)
+		}
+	}
+	return fs
+}
+
+// String returns the fault coverage followed by the list of undetected
+// faults.
+//
+func (r *FaultReport) String() string {
+	var b strings.Builder
+	u := r.Undetected()
+	b.WriteString("fault coverage: " + strconv.Itoa(len(r.Faults)-len(u)) + "/" + strconv.Itoa(len(r.Faults)) +
+		" (" + strconv.FormatFloat(r.Coverage()*100, 'f', 1, 64) + "%)\n")
+	for _, f := range u {
+		b.WriteString("\tundetected: " + f.String() + "\n")
+	}
+	return b.String()
+}
+
+// FaultCoverage runs a fault simulation of the given part with the test
+// vectors of a truth table in the format used by TruthTable.
+//
+// For every wire in the part, including its inputs and outputs, the truth
+// table is run once with the wire stuck at 0 and once stuck at 1. A fault is
+// detected if any output listed in the table header differs from the output of
+// the fault-free part. Expected output values in the table are not checked:
+// use TruthTable to verify the part itself.
+//
+func FaultCoverage(part hwsim.NewPartFn, table string) (*FaultReport, error) {
+	hdr, rows, err := parseTable(part("").PartSpec, table)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newBench(part, hdr)
+	if err != nil {
+		return nil, err
+	}
+	c := b.c
+	s := c.Snapshot()
+
+	// fault-free outputs
+	good := make([][]uint64, len(rows))
+	for i := range rows {
+		b.run(hdr, &rows[i])
+		good[i] = make([]uint64, len(hdr))
+		for j, p := range hdr {
+			good[i][j] = p.v
+		}
+	}
+
+	r := new(FaultReport)
+	for _, n := range c.WireNames() {
+		if n == hwsim.False || n == hwsim.True || n == hwsim.Clk {
+			continue
+		}
+		for _, v := range []bool{false, true} {
+			f := FaultResult{Fault: hwsim.Fault{Wire: n, StuckAt: v}}
+			c.Restore(s)
+			c.ClearFaults()
+			if err := c.InjectFault(f.Fault); err != nil {
+				return nil, err
+			}
+		rows:
+			for i := range rows {
+				b.run(hdr, &rows[i])
+				for j, p := range hdr {
+					if !p.input && p.v != good[i][j] {
+						f.Detected, f.Line = true, rows[i].line
+						break rows
+					}
+				}
+			}
+			r.Faults = append(r.Faults, f)
+		}
+	}
+	c.ClearFaults()
+	return r, nil
+}
@@ -0,0 +1,54 @@
+package hwtest_test
+
+import (
+	"testing"
+
+	hw "github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+	"github.com/db47h/hwsim/hwtest"
+)
+
+func TestFaultCoverage(t *testing.T) {
+	xor, err := hw.Chip("XOR", "a, b", "out",
+		hl.Nand("a=a, b=b, out=nab"),
+		hl.Nand("a=a, b=nab, out=o0"),
+		hl.Nand("a=nab, b=b, out=o1"),
+		hl.Nand("a=o0, b=o1, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := hwtest.FaultCoverage(xor, `
+		a b | out
+		0 0 |   0
+		0 1 |   1
+		1 0 |   1
+		1 1 |   0
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 6 wires: a, b, out, XOR.nab, XOR.o0, XOR.o1
+	if len(r.Faults) != 12 || r.Coverage() != 1 {
+		t.Fatalf("exhaustive test vectors:\n%v", r)
+	}
+
+	r, err = hwtest.FaultCoverage(xor, `
+		a b | out
+		0 0 |   0
+		1 1 |   0
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := r.Undetected()
+	if r.Coverage() == 1 || len(u) == 0 {
+		t.Fatalf("partial test vectors:\n%v", r)
+	}
+	for _, f := range u {
+		if f.String() == "a/0" {
+			t.Fatalf("a/0 should be detected by 1 1 | 0:\n%v", r)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwtest
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/db47h/hwsim"
+	"github.com/pkg/errors"
+)
+
+// A port is a named input or output pin or bus of a part.
+//
+type port struct {
+	name  string
+	bits  int
+	input bool
+	v     uint64
+}
+
+// findPort returns the port for the given pin or bus name in the part spec.
+//
+func findPort(p *hwsim.PartSpec, name string) (*port, error) {
+	for _, l := range []struct {
+		pins  []string
+		input bool
+	}{{p.Inputs, true}, {p.Outputs, false}} {
+		for _, n := range l.pins {
+			if n == name {
+				return &port{name: name, bits: 1, input: l.input}, nil
+			}
+		}
+		bits := 0
+		for ; ; bits++ {
+			if !contains(l.pins, name+"["+strconv.Itoa(bits)+"]") {
+				break
+			}
+		}
+		if bits > 0 {
+			return &port{name: name, bits: bits, input: l.input}, nil
+		}
+	}
+	return nil, errors.Errorf("no pin or bus named %q in part %s", name, p.Name)
+}
+
+func contains(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTableValue parses a truth table value: binary digits, or a hexadecimal
+// value with a 0x prefix. A "-" or "x" means "don't care".
+//
+func parseTableValue(s string) (v uint64, dontCare bool, err error) {
+	switch {
+	case s == "-" || s == "x" || s == "X":
+		return 0, true, nil
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		v, err = strconv.ParseUint(s[2:], 16, 64)
+	case strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0B"):
+		v, err = strconv.ParseUint(s[2:], 2, 64)
+	default:
+		v, err = strconv.ParseUint(s, 2, 64)
+	}
+	return v, false, err
+}
+
+func formatBin(v uint64, bits int) string {
+	s := strconv.FormatUint(v, 2)
+	if len(s) < bits {
+		s = strings.Repeat("0", bits-len(s)) + s
+	}
+	return s
+}
+
+// TruthTable tests a part against a textual truth table.
+//
+// The first row of the table is a header that names the input and output pins
+// or buses of the part to test. Each following row holds the values to set
+// on the inputs and the values expected on the outputs. Inputs not listed in
+// the header are set to 0, and outputs not listed are not checked. Values are
+// written in binary, or in hexadecimal with a 0x prefix. A "-" or "x" in an
+// output column means that the output is not checked. Column separators like
+// '|' are ignored, as are empty lines and lines starting with '#'.
+//
+// Each row runs a full clock cycle and checks the outputs after the call to
+// Tock. Sequential parts can be tested more precisely by starting a row with
+// a tick or tock marker: the row then runs only a call to Tick or Tock
+// respectively. For example, a 1 bit register can be tested with:
+//
+//	hwtest.TruthTable(t, bitRegister, `
+//		     in load | out
+//		tick  1    1 |  0
+//		tock  1    1 |  1
+//		tick  0    0 |  1
+//		tock  0    0 |  1
+//	`)
+//
+func TruthTable(t *testing.T, part hwsim.NewPartFn, table string) {
+	t.Helper()
+
+	hdr, rows, err := parseTable(part("").PartSpec, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newBench(part, hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		b.run(hdr, &r)
+		for j, p := range hdr {
+			if r.care[j] && p.v != r.vs[j] {
+				t.Errorf("line %d: %s = %s, expected %s", r.line, p.name, formatBin(p.v, p.bits), formatBin(r.vs[j], p.bits))
+			}
+		}
+	}
+}
+
+const (
+	runTickTock = iota
+	runTick
+	runTock
+)
+
+// A tableRow is a row in a truth table.
+//
+type tableRow struct {
+	line int
+	run  int      // runTickTock, runTick or runTock
+	vs   []uint64 // input values, and expected output values
+	care []bool   // for outputs: whether the output must be checked
+}
+
+// parseTable parses a truth table for the given part. See TruthTable.
+//
+func parseTable(spec *hwsim.PartSpec, table string) (hdr []*port, rows []tableRow, err error) {
+	lines := strings.Split(table, "\n")
+	for i, l := range lines {
+		l = strings.Replace(l, "|", " ", -1)
+		f := strings.Fields(l)
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if hdr == nil {
+			for _, n := range f {
+				p, err := findPort(spec, n)
+				if err != nil {
+					return nil, nil, errors.Errorf("line %d: %v", i+1, err)
+				}
+				hdr = append(hdr, p)
+			}
+			continue
+		}
+		r := tableRow{line: i + 1}
+		switch f[0] {
+		case "tick":
+			r.run, f = runTick, f[1:]
+		case "tock":
+			r.run, f = runTock, f[1:]
+		}
+		if len(f) != len(hdr) {
+			return nil, nil, errors.Errorf("line %d: expected %d values, got %d", i+1, len(hdr), len(f))
+		}
+		r.care = make([]bool, len(f))
+		r.vs = make([]uint64, len(f))
+		for j, s := range f {
+			v, dc, err := parseTableValue(s)
+			if err == nil && hdr[j].bits < 64 && v >= 1<<uint(hdr[j].bits) {
+				err = errors.Errorf("value too large for %d bits", hdr[j].bits)
+			}
+			if err == nil && dc && hdr[j].input {
+				err = errors.New("don't care value used as input")
+			}
+			if err != nil {
+				return nil, nil, errors.Errorf("line %d: invalid value %q for %s: %v", i+1, s, hdr[j].name, err)
+			}
+			r.vs[j], r.care[j] = v, !dc && !hdr[j].input
+		}
+		rows = append(rows, r)
+	}
+	return hdr, rows, nil
+}
+
+// A bench wraps a part in a circuit where the inputs and outputs listed in
+// ports can be accessed directly.
+//
+type bench struct {
+	c *hwsim.Circuit
+}
+
+func newBench(part hwsim.NewPartFn, ports []*port) (*bench, error) {
+	var parts []hwsim.Part
+	var conns []string
+	for _, p := range ports {
+		p := p
+		wire := p.name
+		conns = append(conns, p.name+"="+wire)
+		switch {
+		case p.input && p.bits == 1:
+			parts = append(parts, hwsim.Input(func() bool { return p.v != 0 })("out="+wire))
+		case p.input:
+			parts = append(parts, hwsim.InputN(p.bits, func() uint64 { return p.v })("out="+wire))
+		case p.bits == 1:
+			parts = append(parts, hwsim.Output(func(v bool) {
+				p.v = 0
+				if v {
+					p.v = 1
+				}
+			})("in="+wire))
+		default:
+			parts = append(parts, hwsim.OutputN(p.bits, func(v uint64) { p.v = v })("in="+wire))
+		}
+	}
+	parts = append(parts, part(strings.Join(conns, ", ")))
+	c, err := hwsim.NewCircuit(parts...)
+	if err != nil {
+		return nil, err
+	}
+	return &bench{c: c}, nil
+}
+
+// run sets the inputs of the bench to the values in row r and runs it.
+//
+func (b *bench) run(hdr []*port, r *tableRow) {
+	for j, p := range hdr {
+		if p.input {
+			p.v = r.vs[j]
+		}
+	}
+	switch r.run {
+	case runTick:
+		b.c.Tick()
+	case runTock:
+		b.c.Tock()
+	default:
+		b.c.TickTock()
+	}
+}
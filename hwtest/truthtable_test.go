@@ -0,0 +1,53 @@
+package hwtest_test
+
+import (
+	"testing"
+
+	hw "github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+	"github.com/db47h/hwsim/hwtest"
+)
+
+func TestTruthTable(t *testing.T) {
+	hwtest.TruthTable(t, nand.NewPart, `
+		a b | out
+		0 0 |  1
+		0 1 |  1
+		1 0 |  1
+		1 1 |  0
+	`)
+	hwtest.TruthTable(t, hl.DMux, `
+		# inputs and outputs can be listed in any order
+		a b | sel in
+		0 0 |   0  0
+		1 0 |   0  1
+		0 0 |   1  0
+		0 1 |   1  1
+	`)
+	hwtest.TruthTable(t, hl.MuxN(16), `
+		     a      b sel |    out
+		0x1234 0xabcd   0 | 0x1234
+		0x1234 0xabcd   1 | 0xabcd
+		0x00ff 0xff00   1 |      -
+	`)
+}
+
+func TestTruthTable_sequential(t *testing.T) {
+	bit, err := hw.Chip("Bit", "in, load", "out",
+		hl.Mux("a=out, b=in, sel=load, out=d"),
+		hl.DFF("in=d, out=out"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hwtest.TruthTable(t, bit, `
+		     in load | out
+		tick  1    0 |   0
+		tock  1    0 |   0
+		tick  1    1 |   0
+		tock  1    1 |   1
+		      0    0 |   1
+		tick  0    1 |   1
+		tock  0    1 |   0
+	`)
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"sort"
+	"strings"
+)
+
+// ChipInfo is a read-only view of a chip built with Chip, as returned by
+// Inspect.
+//
+type ChipInfo struct {
+	Name    string     // chip name
+	Inputs  []string   // input pin names
+	Outputs []string   // output pin names
+	Parts   []PartInfo // sub-parts, in the order given to Chip
+	Wires   []WireInfo // wires, sorted by name
+}
+
+// PartInfo describes a sub-part of a chip.
+//
+type PartInfo struct {
+	Name string            // instance name, like "NAND#0". See Circuit.Wire.
+	Spec *PartSpec         // part specification
+	Pins map[string]string // maps the part's connected pins to wire names
+}
+
+// WireInfo describes a wire within a chip.
+//
+// The name of a wire is the name of the chip input or constant that drives
+// it, or the first of the user given names of the wire in lexical order. If
+// the wire has no name, like the wires connected to unused part outputs, it
+// is named after the part instance and pin that drive it, like "NAND#0.out".
+//
+type WireInfo struct {
+	Name    string   // wire name
+	Aliases []string // other names of the wire in the chip, sorted
+	Source  PinRef   // pin that drives the wire
+	Sinks   []PinRef // pins driven by the wire, in no particular order
+}
+
+// PinRef identifies a pin in a chip.
+//
+type PinRef struct {
+	Part int    // index of the part in ChipInfo.Parts, -1 for chip pins and constants
+	Pin  string // pin name
+}
+
+// Inspect returns a description of the internal structure of the given part:
+// its sub-parts, wires and connections. It returns nil if the part was not
+// built with Chip.
+//
+// Inspect can be called recursively on the sub-parts of a chip:
+//
+//	for _, p := range Inspect(part).Parts {
+//		sub := Inspect(p.Spec.NewPart)
+//		...
+//	}
+//
+func Inspect(part NewPartFn) *ChipInfo {
+	c := part("").chip
+	if c == nil {
+		return nil
+	}
+	ci := &ChipInfo{
+		Name:    c.Name,
+		Inputs:  append([]string(nil), c.Inputs...),
+		Outputs: append([]string(nil), c.Outputs...),
+		Parts:   make([]PartInfo, len(c.parts)),
+	}
+	for i, p := range c.parts {
+		ci.Parts[i] = PartInfo{Name: c.names[i], Spec: p, Pins: make(map[string]string)}
+	}
+
+	// user given names by internal wire name
+	names := make(map[string][]string)
+	for wn, as := range c.aliases {
+		for _, a := range as {
+			if !strings.HasPrefix(a, "__") {
+				names[wn] = append(names[wn], a)
+			}
+		}
+	}
+	for _, n := range c.w {
+		if n.src != nil {
+			continue
+		}
+		var ns []string
+		if !strings.HasPrefix(n.name, "__") {
+			ns = append(ns, n.name)
+		}
+		as := names[n.name]
+		sort.Strings(as)
+		ns = append(ns, as...)
+		w := WireInfo{Source: PinRef{n.pin.p, n.pin.name}}
+		if len(ns) > 0 {
+			w.Name, w.Aliases = ns[0], ns[1:]
+		} else {
+			w.Name = c.names[n.pin.p] + "." + n.pin.name
+		}
+		var walk func(n *node)
+		walk = func(n *node) {
+			for _, o := range n.outs {
+				if o.pin.p >= 0 || o.typ != typeUnknown {
+					w.Sinks = append(w.Sinks, PinRef{o.pin.p, o.pin.name})
+				}
+				walk(o)
+			}
+		}
+		walk(n)
+		for _, s := range append([]PinRef{w.Source}, w.Sinks...) {
+			if s.Part >= 0 {
+				ci.Parts[s.Part].Pins[s.Pin] = w.Name
+			}
+		}
+		ci.Wires = append(ci.Wires, w)
+	}
+	sort.Slice(ci.Wires, func(i, j int) bool { return ci.Wires[i].Name < ci.Wires[j].Name })
+	return ci
+}
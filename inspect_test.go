@@ -0,0 +1,60 @@
+package hwsim_test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+func TestInspect(t *testing.T) {
+	ci := hwsim.Inspect(tl.xor)
+	if ci == nil {
+		t.Fatal("no info for xor")
+	}
+	if ci.Name != "xor" || !reflect.DeepEqual(ci.Inputs, []string{"a", "b"}) || !reflect.DeepEqual(ci.Outputs, []string{"out"}) {
+		t.Fatalf("bad chip interface: %s %v %v", ci.Name, ci.Inputs, ci.Outputs)
+	}
+	var names []string
+	for _, p := range ci.Parts {
+		names = append(names, p.Name)
+		if p.Spec.Name != "NAND" {
+			t.Errorf("part %s: got spec %s, expected NAND", p.Name, p.Spec.Name)
+		}
+	}
+	if exp := []string{"NAND#0", "NAND#1", "NAND#2", "NAND#3"}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("got parts %v, expected %v", names, exp)
+	}
+	if p := ci.Parts[1].Pins; p["a"] != "a" || p["b"] != "nab" || p["out"] != "o0" {
+		t.Errorf("got pins %v for part 1", p)
+	}
+
+	type wire struct {
+		name  string
+		src   hwsim.PinRef
+		sinks []hwsim.PinRef
+	}
+	var ws []wire
+	for _, w := range ci.Wires {
+		sort.Slice(w.Sinks, func(i, j int) bool {
+			return w.Sinks[i].Part < w.Sinks[j].Part || w.Sinks[i].Part == w.Sinks[j].Part && w.Sinks[i].Pin < w.Sinks[j].Pin
+		})
+		ws = append(ws, wire{w.Name, w.Source, w.Sinks})
+	}
+	exp := []wire{
+		{"a", hwsim.PinRef{Part: -1, Pin: "a"}, []hwsim.PinRef{{0, "a"}, {1, "a"}}},
+		{"b", hwsim.PinRef{Part: -1, Pin: "b"}, []hwsim.PinRef{{0, "b"}, {2, "b"}}},
+		{"nab", hwsim.PinRef{Part: 0, Pin: "out"}, []hwsim.PinRef{{1, "b"}, {2, "a"}}},
+		{"o0", hwsim.PinRef{Part: 1, Pin: "out"}, []hwsim.PinRef{{3, "a"}}},
+		{"o1", hwsim.PinRef{Part: 2, Pin: "out"}, []hwsim.PinRef{{3, "b"}}},
+		{"out", hwsim.PinRef{Part: 3, Pin: "out"}, []hwsim.PinRef{{-1, "out"}}},
+	}
+	if !reflect.DeepEqual(ws, exp) {
+		t.Errorf("got wires\n%v\nexpected\n%v", ws, exp)
+	}
+
+	if ci = hwsim.Inspect(tl.nand); ci != nil {
+		t.Errorf("got %v for NAND", ci)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"unicode"
 
 	"github.com/db47h/hwsim/internal/lex"
-	"github.com/pkg/errors"
 )
 
 // Tokens
@@ -19,6 +18,9 @@ const (
 	Int
 	Range
 	Equal
+	Literal
+	BraceOpen
+	BraceClose
 )
 
 // Lexer returns a new lexer for i/o specs and connection descriptions.
@@ -42,6 +44,10 @@ func lexInit(l *lex.Lexer) lex.StateFn {
 		l.Emit(BracketClose, "]")
 	case r == ',':
 		l.Emit(Comma, ",")
+	case r == '{':
+		l.Emit(BraceOpen, "{")
+	case r == '}':
+		l.Emit(BraceClose, "}")
 	case '0' <= r && r <= '9':
 		return lexNumber
 	case r == '=':
@@ -62,17 +68,52 @@ func lexInit(l *lex.Lexer) lex.StateFn {
 }
 
 func lexNumber(l *lex.Lexer) lex.StateFn {
-	var buf strings.Builder
-	buf.WriteRune(l.Current())
-	i := int(l.Current() - '0')
-	r := l.Next()
-	for '0' <= r && r <= '9' {
-		i = i*10 + int(r-'0')
-		buf.WriteRune(r)
-		r = l.Next()
+	if l.Current() == '0' {
+		switch l.Peek() {
+		case 'x', 'X':
+			l.Next()
+			return lexBase(l, Literal, 16, 0, 0)
+		case 'b', 'B':
+			l.Next()
+			return lexBase(l, Literal, 2, 0, 0)
+		}
 	}
-	l.Backup()
-	l.Emit(Int, i)
+	return lexBase(l, Int, 10, uint64(l.Current()-'0'), 1)
+}
+
+// lexBase lexes the digits of an integer value in the given base and emits it
+// as a uint64 with type t. v is the value of the n digits already read.
+//
+func lexBase(l *lex.Lexer, t lex.Type, base uint64, v uint64, n int) lex.StateFn {
+	for {
+		r := l.Next()
+		var d uint64
+		switch {
+		case '0' <= r && r <= '9':
+			d = uint64(r - '0')
+		case 'a' <= r && r <= 'f':
+			d = uint64(r-'a') + 10
+		case 'A' <= r && r <= 'F':
+			d = uint64(r-'A') + 10
+		default:
+			d = base
+		}
+		if d >= base {
+			l.Backup()
+			break
+		}
+		if v > (^uint64(0)-d)/base {
+			l.Errorf(l.Pos(), "literal value overflows 64 bits")
+			return lexEOF
+		}
+		v = v*base + d
+		n++
+	}
+	if n == 0 {
+		l.Errorf(l.Pos(), "missing digits in literal value")
+		return lexEOF
+	}
+	l.Emit(t, v)
 	return nil
 }
 
@@ -120,6 +161,38 @@ type PinRange struct {
 	End   int
 }
 
+// Constant is a constant value literal.
+//
+type Constant struct {
+	Value uint64
+	pos   lex.Pos
+}
+
+// Pos implements PinExpr
+func (c *Constant) Pos() int       { return int(c.pos) }
+func (c *Constant) String() string { return strconv.FormatUint(c.Value, 10) }
+
+// Concat is a concatenation of pins {p1, p2, ...}
+type Concat struct {
+	Pins []PinExpr
+	pos  lex.Pos
+}
+
+// Pos implements PinExpr
+func (c *Concat) Pos() int { return int(c.pos) }
+func (c *Concat) String() string {
+	var b strings.Builder
+	b.WriteRune('{')
+	for i, p := range c.Pins {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(p.String())
+	}
+	b.WriteRune('}')
+	return b.String()
+}
+
 // PinAssignment is a part pin to chip pin assignment. pp=pc
 //
 type PinAssignment struct {
@@ -128,6 +201,7 @@ type PinAssignment struct {
 }
 
 // PinExpr is a pin declaration expression.
+//
 type PinExpr interface {
 	Pos() int
 	String() string
@@ -203,7 +277,16 @@ func (p *Parser) Next(conns, allowRange bool) (PinExpr, error) {
 		return nil, parseError(p.Input, p.i.Pos, "missing '=' in connection description")
 	}
 	p.i = p.l.Lex()
-	pin2, err := p.getPin(allowRange)
+	var pin2 PinExpr
+	switch p.i.Type {
+	case Int, Literal:
+		pin2 = &Constant{p.i.Value.(uint64), p.i.Pos}
+		p.i = p.l.Lex()
+	case BraceOpen:
+		pin2, err = p.getConcat(allowRange)
+	default:
+		pin2, err = p.getPin(allowRange)
+	}
 	if err != nil {
 		p.state = stateDone
 		return nil, err
@@ -220,6 +303,9 @@ func (p *Parser) Next(conns, allowRange bool) (PinExpr, error) {
 }
 
 func (p *Parser) getPin(allowRange bool) (PinExpr, error) {
+	if p.i.Type == lex.Error {
+		return nil, parseError(p.Input, p.i.Pos, p.i.Value.(string))
+	}
 	if p.i.Type != Ident {
 		return nil, parseError(p.Input, p.i.Pos, "expected pin name")
 	}
@@ -231,10 +317,16 @@ func (p *Parser) getPin(allowRange bool) (PinExpr, error) {
 	}
 	// expect bus size
 	i := p.l.Lex()
+	if i.Type == lex.Error {
+		return nil, parseError(p.Input, i.Pos, i.Value.(string))
+	}
 	if i.Type != Int {
 		return nil, parseError(p.Input, i.Pos, "integer value expected after '['")
 	}
-	start := i.Value.(int)
+	start, err := index(p.Input, i)
+	if err != nil {
+		return nil, err
+	}
 	end := -1
 	i = p.l.Lex()
 	if i.Type == Range {
@@ -242,10 +334,15 @@ func (p *Parser) getPin(allowRange bool) (PinExpr, error) {
 			return nil, parseError(p.Input, i.Pos, "pin ranges forbidden in this context")
 		}
 		i = p.l.Lex()
+		if i.Type == lex.Error {
+			return nil, parseError(p.Input, i.Pos, i.Value.(string))
+		}
 		if i.Type != Int {
 			return nil, parseError(p.Input, i.Pos, "integer value expected after '..'")
 		}
-		end = i.Value.(int)
+		if end, err = index(p.Input, i); err != nil {
+			return nil, err
+		}
 		i = p.l.Lex()
 	}
 	if i.Type != BracketClose {
@@ -258,6 +355,52 @@ func (p *Parser) getPin(allowRange bool) (PinExpr, error) {
 	return &PinIndex{pin, start}, nil
 }
 
+// maxIndex is the largest bus index or range bound.
+//
+const maxIndex = 1<<31 - 1
+
+// index returns the value of an Int item used as a bus index.
+//
+func index(in string, i lex.Item) (int, error) {
+	v := i.Value.(uint64)
+	if v > maxIndex {
+		return 0, parseError(in, i.Pos, "bus index out of range")
+	}
+	return int(v), nil
+}
+
+func (p *Parser) getConcat(allowRange bool) (PinExpr, error) {
+	c := &Concat{pos: p.i.Pos}
+	for {
+		p.i = p.l.Lex()
+		pin, err := p.getPin(allowRange)
+		if err != nil {
+			return nil, err
+		}
+		c.Pins = append(c.Pins, pin)
+		switch p.i.Type {
+		case Comma:
+			continue
+		case BraceClose:
+			p.i = p.l.Lex()
+			return c, nil
+		}
+		return nil, parseError(p.Input, p.i.Pos, "expected ',' or '}' in pin concatenation")
+	}
+}
+
+// Error is a parse error.
+//
+type Error struct {
+	Input string // input string
+	Pos   int    // rune offset of the offending token in Input
+	Msg   string // error message
+}
+
+func (e *Error) Error() string {
+	return "in " + strconv.Quote(e.Input) + " at pos " + strconv.Itoa(e.Pos+1) + ": " + e.Msg
+}
+
 func parseError(in string, pos lex.Pos, msg string) error {
-	return errors.Errorf("in %q at pos %d: %s", in, pos+1, msg)
+	return &Error{in, int(pos), msg}
 }
@@ -223,7 +223,7 @@ func (l *Lexer) next() rune {
 		r = EOF
 	case err != nil && err != io.EOF:
 		r = EOF
-		l.Errorf(l.n, err.Error())
+		l.Errorf(l.n, "%s", err.Error())
 	}
 	if r == '\n' {
 		l.l++
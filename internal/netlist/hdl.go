@@ -0,0 +1,257 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package netlist
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// ParseHDL parses chip declarations in the nand2tetris HDL format:
+//
+//	// comment
+//	CHIP Xor {
+//		IN a, b;
+//		OUT out;
+//		PARTS:
+//		Nand(a=a, b=b, out=nab);
+//		...
+//	}
+//
+// Pin and connection lists use the same syntax as ParseIOSpec and
+// ParseConnections in package hwsim. Several chips can be declared in the same
+// file.
+//
+func ParseHDL(r io.Reader, filename string) ([]*ChipDecl, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &scanner{src: []rune(string(b)), file: filename, line: 1}
+	var decls []*ChipDecl
+	for {
+		s.skip()
+		if s.eof() {
+			return decls, nil
+		}
+		d, err := s.chip()
+		if err != nil {
+			return nil, err
+		}
+		decls = append(decls, d)
+	}
+}
+
+// ParseJSON parses chip declarations from a JSON netlist. The input is either a
+// single chip object or an array of chip objects:
+//
+//	[{
+//		"name": "Xor",
+//		"in": "a, b",
+//		"out": "out",
+//		"parts": [
+//			{ "part": "Nand", "conns": "a=a, b=b, out=nab" },
+//			...
+//		]
+//	}]
+//
+func ParseJSON(r io.Reader, filename string) ([]*ChipDecl, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var decls []*ChipDecl
+	if err = json.Unmarshal(b, &decls); err != nil {
+		var d ChipDecl
+		if err := json.Unmarshal(b, &d); err != nil {
+			return nil, errors.Wrap(err, filename)
+		}
+		decls = []*ChipDecl{&d}
+	}
+	for _, d := range decls {
+		d.File = filename
+		if d.Name == "" {
+			return nil, errors.New(filename + ": chip with no name")
+		}
+	}
+	return decls, nil
+}
+
+type scanner struct {
+	src  []rune
+	pos  int
+	file string
+	line int
+}
+
+func (s *scanner) eof() bool { return s.pos >= len(s.src) }
+
+func (s *scanner) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("%s:%d: "+format, append([]interface{}{s.file, s.line}, args...)...)
+}
+
+func (s *scanner) next() rune {
+	r := s.src[s.pos]
+	s.pos++
+	if r == '\n' {
+		s.line++
+	}
+	return r
+}
+
+// skip skips white space and comments.
+//
+func (s *scanner) skip() {
+	for !s.eof() {
+		r := s.src[s.pos]
+		switch {
+		case unicode.IsSpace(r):
+			s.next()
+		case s.hasPrefix("//"):
+			for !s.eof() && s.src[s.pos] != '\n' {
+				s.next()
+			}
+		case s.hasPrefix("/*"):
+			s.pos += 2
+			for !s.eof() && !s.hasPrefix("*/") {
+				s.next()
+			}
+			s.pos += 2
+		default:
+			return
+		}
+	}
+}
+
+func (s *scanner) hasPrefix(p string) bool {
+	for i, r := range p {
+		if s.pos+i >= len(s.src) || s.src[s.pos+i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *scanner) ident() string {
+	s.skip()
+	start := s.pos
+	for !s.eof() {
+		r := s.src[s.pos]
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			break
+		}
+		s.pos++
+	}
+	return string(s.src[start:s.pos])
+}
+
+func (s *scanner) expect(r rune) error {
+	s.skip()
+	if s.eof() {
+		return s.errorf("unexpected end of file, expected %q", r)
+	}
+	if c := s.src[s.pos]; c != r {
+		return s.errorf("unexpected %q, expected %q", c, r)
+	}
+	s.next()
+	return nil
+}
+
+// until returns the text up to the delimiter r (excluded) with comments
+// and redundant white space stripped, and skips the delimiter.
+//
+func (s *scanner) until(r rune) (string, error) {
+	var b strings.Builder
+	for {
+		if s.hasPrefix("//") || s.hasPrefix("/*") {
+			s.skip()
+			b.WriteRune(' ')
+			continue
+		}
+		if s.eof() {
+			return "", s.errorf("unexpected end of file, expected %q", r)
+		}
+		c := s.next()
+		if c == r {
+			return strings.Join(strings.Fields(b.String()), " "), nil
+		}
+		b.WriteRune(c)
+	}
+}
+
+func (s *scanner) chip() (*ChipDecl, error) {
+	if id := s.ident(); id != "CHIP" {
+		return nil, s.errorf("expected CHIP, got %q", id)
+	}
+	d := &ChipDecl{File: s.file, Line: s.line}
+	if d.Name = s.ident(); d.Name == "" {
+		return nil, s.errorf("missing chip name")
+	}
+	if err := s.expect('{'); err != nil {
+		return nil, err
+	}
+	for {
+		s.skip()
+		if !s.eof() && s.src[s.pos] == '}' {
+			s.next()
+			return d, nil
+		}
+		switch id := s.ident(); id {
+		case "IN", "OUT":
+			l, err := s.until(';')
+			if err != nil {
+				return nil, err
+			}
+			if id == "IN" {
+				d.Inputs = l
+			} else {
+				d.Outputs = l
+			}
+		case "PARTS", "BUILTIN", "CLOCKED":
+			if id != "PARTS" {
+				return nil, s.errorf("%s chips are not supported", id)
+			}
+			if err := s.expect(':'); err != nil {
+				return nil, err
+			}
+			if err := s.parts(d); err != nil {
+				return nil, err
+			}
+		case "":
+			return nil, s.errorf("unexpected end of chip declaration")
+		default:
+			return nil, s.errorf("unexpected %q in chip %s", id, d.Name)
+		}
+	}
+}
+
+func (s *scanner) parts(d *ChipDecl) error {
+	for {
+		s.skip()
+		if s.eof() || s.src[s.pos] == '}' {
+			return nil
+		}
+		p := PartDecl{Line: s.line}
+		if p.Name = s.ident(); p.Name == "" {
+			return s.errorf("expected part name")
+		}
+		if err := s.expect('('); err != nil {
+			return err
+		}
+		c, err := s.until(')')
+		if err != nil {
+			return err
+		}
+		p.Conns = c
+		if err = s.expect(';'); err != nil {
+			return err
+		}
+		d.Parts = append(d.Parts, p)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+// Package netlist loads chip definitions from HDL or JSON netlist files.
+//
+package netlist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/db47h/hwsim"
+	"github.com/db47h/hwsim/hwlib"
+	"github.com/pkg/errors"
+)
+
+// A ChipDecl is a chip declaration as found in an HDL or JSON file.
+//
+type ChipDecl struct {
+	Name    string     `json:"name"`
+	Inputs  string     `json:"in"`
+	Outputs string     `json:"out"`
+	Parts   []PartDecl `json:"parts"`
+	File    string     `json:"-"`
+	Line    int        `json:"-"`
+}
+
+// A PartDecl is a part within a chip declaration.
+//
+type PartDecl struct {
+	Name  string `json:"part"`
+	Conns string `json:"conns"`
+	Line  int    `json:"-"`
+}
+
+// Builtins returns the built-in parts available to chip declarations, indexed
+// by name. The names follow the nand2tetris conventions.
+//
+func Builtins() map[string]hwsim.NewPartFn {
+	return map[string]hwsim.NewPartFn{
+		"Nand":      hwlib.Nand,
+		"Not":       hwlib.Not,
+		"And":       hwlib.And,
+		"Or":        hwlib.Or,
+		"Nor":       hwlib.Nor,
+		"Xor":       hwlib.Xor,
+		"Xnor":      hwlib.Xnor,
+		"Mux":       hwlib.Mux,
+		"DMux":      hwlib.DMux,
+		"Not16":     hwlib.NotN(16),
+		"And16":     hwlib.AndN(16),
+		"Or16":      hwlib.OrN(16),
+		"Mux16":     hwlib.MuxN(16),
+		"Or8Way":    hwlib.OrNWay(8),
+		"Mux4Way16": hwlib.MuxMWayN(4, 16),
+		"Mux8Way16": hwlib.MuxMWayN(8, 16),
+		"DMux4Way":  hwlib.DMuxNWay(4),
+		"DMux8Way":  hwlib.DMuxNWay(8),
+		"DFF":       hwlib.DFF,
+	}
+}
+
+// A Library resolves part names to NewPartFn's. Names are looked up in the
+// built-in parts, then in the chips declared in loaded files, and finally in
+// files named after the part (with a .hdl extension) in the library search
+// directories.
+//
+type Library struct {
+	Dirs     []string
+	parts    map[string]hwsim.NewPartFn
+	decls    map[string]*ChipDecl
+	building map[string]bool
+}
+
+// NewLibrary returns a new library with the built-in parts and the given
+// search directories.
+//
+func NewLibrary(dirs ...string) *Library {
+	return &Library{
+		Dirs:     dirs,
+		parts:    Builtins(),
+		decls:    make(map[string]*ChipDecl),
+		building: make(map[string]bool),
+	}
+}
+
+// Add adds a part to the library.
+//
+func (l *Library) Add(name string, p hwsim.NewPartFn) {
+	l.parts[name] = p
+}
+
+// Declare adds chip declarations to the library. The chips are built the first
+// time they are looked up.
+//
+func (l *Library) Declare(decls ...*ChipDecl) error {
+	for _, d := range decls {
+		if _, ok := l.decls[d.Name]; ok {
+			return errors.Errorf("%s: chip %s redeclared", d.pos(), d.Name)
+		}
+		l.decls[d.Name] = d
+	}
+	return nil
+}
+
+// LoadFile parses the given HDL or JSON file, depending on its extension, and
+// adds its chip declarations to the library. It returns the declarations
+// found in the file.
+//
+func (l *Library) LoadFile(name string) ([]*ChipDecl, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var decls []*ChipDecl
+	if strings.EqualFold(filepath.Ext(name), ".json") {
+		decls, err = ParseJSON(f, name)
+	} else {
+		decls, err = ParseHDL(f, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = l.Declare(decls...); err != nil {
+		return nil, err
+	}
+	return decls, nil
+}
+
+// Lookup returns the NewPartFn for the named part.
+//
+func (l *Library) Lookup(name string) (hwsim.NewPartFn, error) {
+	if p := l.parts[name]; p != nil {
+		return p, nil
+	}
+	d := l.decls[name]
+	if d == nil {
+		for _, dir := range l.Dirs {
+			fn := filepath.Join(dir, name+".hdl")
+			if _, err := os.Stat(fn); err != nil {
+				continue
+			}
+			if _, err := l.LoadFile(fn); err != nil {
+				return nil, err
+			}
+			d = l.decls[name]
+			break
+		}
+		if d == nil {
+			return nil, errors.Errorf("unknown part %s", name)
+		}
+	}
+	if l.building[name] {
+		return nil, errors.Errorf("%s: recursive definition of chip %s", d.pos(), name)
+	}
+	l.building[name] = true
+	defer delete(l.building, name)
+	p, err := l.build(d)
+	if err != nil {
+		return nil, err
+	}
+	l.parts[name] = p
+	return p, nil
+}
+
+func (l *Library) build(d *ChipDecl) (hwsim.NewPartFn, error) {
+	parts := make([]hwsim.Part, 0, len(d.Parts))
+	for i := range d.Parts {
+		pd := &d.Parts[i]
+		fn, err := l.Lookup(pd.Name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d", d.File, pd.Line)
+		}
+		p, err := newPart(fn, pd.Conns)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d: %s", d.File, pd.Line, pd.Name)
+		}
+		parts = append(parts, p)
+	}
+	p, err := hwsim.Chip(d.Name, d.Inputs, d.Outputs, parts...)
+	if err != nil {
+		// report errors in a part's connections at the part's line
+		if we, ok := err.(*hwsim.WiringError); ok && we.Part >= 0 {
+			return nil, errors.Wrapf(err, "%s:%d", d.File, d.Parts[we.Part].Line)
+		}
+		return nil, errors.Wrapf(err, "%s: chip %s", d.pos(), d.Name)
+	}
+	return p, nil
+}
+
+// newPart calls fn with the connection string c and recovers from parse
+// errors.
+//
+func newPart(fn hwsim.NewPartFn, c string) (p hwsim.Part, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+				return
+			}
+			panic(r)
+		}
+	}()
+	return fn(c), nil
+}
+
+func (d *ChipDecl) pos() string {
+	if d.Line > 0 {
+		return fmt.Sprintf("%s:%d", d.File, d.Line)
+	}
+	return d.File
+}
@@ -0,0 +1,49 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+// Package pins provides helpers to build I/O specifications and wire names
+// from generated pin names.
+//
+package pins
+
+import (
+	"strings"
+	"unicode"
+)
+
+// IOSpec returns an I/O specification string for the given pin names, as
+// expected by hwsim.ParseIOSpec.
+//
+func IOSpec(pins []string) string {
+	s := make([]string, len(pins))
+	for i, p := range pins {
+		// "a[3]" is a 3 bits bus in an I/O spec, a[3..3] is the single pin a[3].
+		if j := strings.IndexByte(p, '['); j >= 0 {
+			idx := p[j+1 : len(p)-1]
+			p = p[:j] + "[" + idx + ".." + idx + "]"
+		}
+		s[i] = p
+	}
+	return strings.Join(s, ", ")
+}
+
+// Ident returns name with the characters not allowed in identifiers replaced
+// with '_', and prefixed with '_' if it is empty or starts with a digit. A
+// trailing bus index is kept.
+//
+func Ident(name string) string {
+	base, idx := name, ""
+	if i := strings.IndexByte(name, '['); i >= 0 && strings.HasSuffix(name, "]") {
+		base, idx = name[:i], name[i:]
+	}
+	s := []rune(base)
+	for i, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			s[i] = '_'
+		}
+	}
+	if len(s) == 0 || unicode.IsDigit(s[0]) {
+		s = append([]rune{'_'}, s...)
+	}
+	return string(s) + idx
+}
@@ -0,0 +1,484 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+// Package sat implements a small CDCL SAT solver.
+//
+// Variables are numbered from 1, and literals are represented as in the
+// DIMACS format: v for variable v, -v for its negation.
+//
+package sat
+
+// lit is the internal representation of a literal: 2*v for variable v
+// (0 based), 2*v+1 for its negation.
+//
+type lit int
+
+func mkLit(l int) lit {
+	if l < 0 {
+		return lit(2*(-l-1) + 1)
+	}
+	return lit(2 * (l - 1))
+}
+
+func (l lit) v() int   { return int(l) >> 1 }
+func (l lit) neg() lit { return l ^ 1 }
+
+// values of variables
+const (
+	lFalse int8 = -1
+	lUndef int8 = 0
+	lTrue  int8 = 1
+)
+
+// Solver is a SAT solver. The zero value is not usable, use New to create a
+// new Solver.
+//
+type Solver struct {
+	clauses  [][]lit
+	watches  [][]int // clause indices watching a literal
+	assigns  []int8
+	level    []int
+	reason   []int // index of the clause that implied a variable, or -1
+	polarity []bool
+	activity []float64
+	varInc   float64
+	heap     varHeap
+	seen     []bool
+
+	trail    []lit
+	trailLim []int
+	qhead    int
+
+	unsat bool
+}
+
+// New returns a new Solver.
+//
+func New() *Solver {
+	s := &Solver{varInc: 1}
+	s.heap.act = &s.activity
+	return s
+}
+
+// NewVar creates a new variable and returns its number.
+//
+func (s *Solver) NewVar() int {
+	v := len(s.assigns)
+	s.watches = append(s.watches, nil, nil)
+	s.assigns = append(s.assigns, lUndef)
+	s.level = append(s.level, 0)
+	s.reason = append(s.reason, -1)
+	s.polarity = append(s.polarity, false)
+	s.activity = append(s.activity, 0)
+	s.seen = append(s.seen, false)
+	s.heap.push(v)
+	return v + 1
+}
+
+// NumVars returns the number of variables.
+//
+func (s *Solver) NumVars() int {
+	return len(s.assigns)
+}
+
+// NumClauses returns the number of clauses, including learnt clauses.
+//
+func (s *Solver) NumClauses() int {
+	return len(s.clauses)
+}
+
+func (s *Solver) value(l lit) int8 {
+	v := s.assigns[l.v()]
+	if l&1 != 0 {
+		return -v
+	}
+	return v
+}
+
+// AddClause adds a clause to the problem. Clauses must be added before
+// calling Solve.
+//
+func (s *Solver) AddClause(lits ...int) {
+	if s.unsat {
+		return
+	}
+	c := make([]lit, 0, len(lits))
+	for _, l := range lits {
+		if l == 0 || abs(l) > len(s.assigns) {
+			panic("invalid literal")
+		}
+		x := mkLit(l)
+		switch s.value(x) {
+		case lTrue:
+			return // satisfied at level 0
+		case lFalse:
+			continue
+		}
+		dup := false
+		for _, y := range c {
+			if y == x {
+				dup = true
+				break
+			}
+			if y == x.neg() {
+				return // tautology
+			}
+		}
+		if !dup {
+			c = append(c, x)
+		}
+	}
+	switch len(c) {
+	case 0:
+		s.unsat = true
+	case 1:
+		s.enqueue(c[0], -1)
+		if s.propagate() >= 0 {
+			s.unsat = true
+		}
+	default:
+		s.attach(c)
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+func (s *Solver) attach(c []lit) int {
+	i := len(s.clauses)
+	s.clauses = append(s.clauses, c)
+	s.watches[c[0]] = append(s.watches[c[0]], i)
+	s.watches[c[1]] = append(s.watches[c[1]], i)
+	return i
+}
+
+func (s *Solver) decisionLevel() int {
+	return len(s.trailLim)
+}
+
+func (s *Solver) enqueue(l lit, reason int) {
+	v := l.v()
+	if l&1 != 0 {
+		s.assigns[v] = lFalse
+	} else {
+		s.assigns[v] = lTrue
+	}
+	s.level[v] = s.decisionLevel()
+	s.reason[v] = reason
+	s.trail = append(s.trail, l)
+}
+
+// propagate performs unit propagation. It returns the index of a conflicting
+// clause, or -1 if there is no conflict.
+//
+func (s *Solver) propagate() int {
+	for s.qhead < len(s.trail) {
+		fl := s.trail[s.qhead].neg() // literal that became false
+		s.qhead++
+		ws := s.watches[fl]
+		i, j := 0, 0
+		for i < len(ws) {
+			ci := ws[i]
+			i++
+			c := s.clauses[ci]
+			if c[0] == fl {
+				c[0], c[1] = c[1], fl
+			}
+			if s.value(c[0]) == lTrue {
+				ws[j] = ci
+				j++
+				continue
+			}
+			// look for a new literal to watch
+			found := false
+			for k := 2; k < len(c); k++ {
+				if s.value(c[k]) != lFalse {
+					c[1], c[k] = c[k], c[1]
+					s.watches[c[1]] = append(s.watches[c[1]], ci)
+					found = true
+					break
+				}
+			}
+			if found {
+				continue
+			}
+			ws[j] = ci
+			j++
+			if s.value(c[0]) == lFalse {
+				// conflict: keep remaining watches
+				for i < len(ws) {
+					ws[j] = ws[i]
+					i++
+					j++
+				}
+				s.watches[fl] = ws[:j]
+				s.qhead = len(s.trail)
+				return ci
+			}
+			s.enqueue(c[0], ci)
+		}
+		s.watches[fl] = ws[:j]
+	}
+	return -1
+}
+
+// analyze returns a learnt clause from a conflict, along with the decision
+// level to backtrack to. The first literal of the learnt clause is the
+// asserting literal.
+//
+func (s *Solver) analyze(confl int) ([]lit, int) {
+	learnt := []lit{0}
+	pathC := 0
+	p := lit(-1)
+	idx := len(s.trail) - 1
+	for {
+		c := s.clauses[confl]
+		start := 0
+		if p >= 0 {
+			start = 1
+		}
+		for _, q := range c[start:] {
+			v := q.v()
+			if s.seen[v] || s.level[v] == 0 {
+				continue
+			}
+			s.bump(v)
+			s.seen[v] = true
+			if s.level[v] >= s.decisionLevel() {
+				pathC++
+			} else {
+				learnt = append(learnt, q)
+			}
+		}
+		for !s.seen[s.trail[idx].v()] {
+			idx--
+		}
+		p = s.trail[idx]
+		idx--
+		confl = s.reason[p.v()]
+		s.seen[p.v()] = false
+		pathC--
+		if pathC == 0 {
+			break
+		}
+	}
+	learnt[0] = p.neg()
+
+	// find the backtrack level and put the literal with the highest level
+	// in second position.
+	bt := 0
+	for i := 1; i < len(learnt); i++ {
+		if lv := s.level[learnt[i].v()]; lv > bt {
+			bt = lv
+			learnt[1], learnt[i] = learnt[i], learnt[1]
+		}
+	}
+	for _, l := range learnt {
+		s.seen[l.v()] = false
+	}
+	return learnt, bt
+}
+
+func (s *Solver) bump(v int) {
+	s.activity[v] += s.varInc
+	if s.activity[v] > 1e100 {
+		for i := range s.activity {
+			s.activity[i] *= 1e-100
+		}
+		s.varInc *= 1e-100
+	}
+	if s.heap.contains(v) {
+		s.heap.up(s.heap.index[v])
+	}
+}
+
+func (s *Solver) cancelUntil(level int) {
+	if s.decisionLevel() <= level {
+		return
+	}
+	for i := len(s.trail) - 1; i >= s.trailLim[level]; i-- {
+		v := s.trail[i].v()
+		s.assigns[v] = lUndef
+		s.reason[v] = -1
+		s.polarity[v] = s.trail[i]&1 == 0
+		if !s.heap.contains(v) {
+			s.heap.push(v)
+		}
+	}
+	s.trail = s.trail[:s.trailLim[level]]
+	s.trailLim = s.trailLim[:level]
+	s.qhead = len(s.trail)
+}
+
+func (s *Solver) pickBranch() lit {
+	for s.heap.len() > 0 {
+		v := s.heap.pop()
+		if s.assigns[v] == lUndef {
+			if s.polarity[v] {
+				return lit(2 * v)
+			}
+			return lit(2*v + 1)
+		}
+	}
+	return -1
+}
+
+// luby returns the i-th element of the Luby sequence (i >= 0).
+//
+func luby(i int) int {
+	size, seq := 1, 0
+	for size < i+1 {
+		seq++
+		size = 2*size + 1
+	}
+	x := 1
+	for size-1 != i {
+		size = (size - 1) >> 1
+		seq--
+		i %= size
+	}
+	for ; seq > 0; seq-- {
+		x *= 2
+	}
+	return x
+}
+
+// Solve solves the problem. It returns true if the problem is satisfiable, in
+// which case the values of the variables in the solution found can be
+// retrieved with Value.
+//
+func (s *Solver) Solve() bool {
+	if s.unsat {
+		return false
+	}
+	if s.propagate() >= 0 {
+		s.unsat = true
+		return false
+	}
+	for restarts := 0; ; restarts++ {
+		switch s.search(100 * luby(restarts)) {
+		case lTrue:
+			return true
+		case lFalse:
+			s.unsat = true
+			return false
+		}
+		s.cancelUntil(0)
+	}
+}
+
+// search runs the CDCL loop until the problem is solved or the given number
+// of conflicts is reached, in which case it returns lUndef.
+//
+func (s *Solver) search(maxConflicts int) int8 {
+	conflicts := 0
+	for {
+		confl := s.propagate()
+		if confl >= 0 {
+			conflicts++
+			if s.decisionLevel() == 0 {
+				return lFalse
+			}
+			learnt, bt := s.analyze(confl)
+			s.cancelUntil(bt)
+			if len(learnt) == 1 {
+				s.enqueue(learnt[0], -1)
+			} else {
+				s.enqueue(learnt[0], s.attach(learnt))
+			}
+			s.varInc /= 0.95
+			continue
+		}
+		if conflicts >= maxConflicts {
+			return lUndef
+		}
+		l := s.pickBranch()
+		if l < 0 {
+			return lTrue
+		}
+		s.trailLim = append(s.trailLim, len(s.trail))
+		s.enqueue(l, -1)
+	}
+}
+
+// Value returns the value of variable v in the last solution found by Solve.
+//
+func (s *Solver) Value(v int) bool {
+	return s.assigns[v-1] == lTrue
+}
+
+// varHeap is a binary max-heap of variables ordered by activity.
+//
+type varHeap struct {
+	act   *[]float64
+	heap  []int
+	index []int // position of variables in heap, -1 if not in heap
+}
+
+func (h *varHeap) len() int { return len(h.heap) }
+
+func (h *varHeap) less(i, j int) bool {
+	return (*h.act)[h.heap[i]] > (*h.act)[h.heap[j]]
+}
+
+func (h *varHeap) swap(i, j int) {
+	h.heap[i], h.heap[j] = h.heap[j], h.heap[i]
+	h.index[h.heap[i]] = i
+	h.index[h.heap[j]] = j
+}
+
+func (h *varHeap) contains(v int) bool {
+	return v < len(h.index) && h.index[v] >= 0
+}
+
+func (h *varHeap) push(v int) {
+	for len(h.index) <= v {
+		h.index = append(h.index, -1)
+	}
+	h.heap = append(h.heap, v)
+	h.index[v] = len(h.heap) - 1
+	h.up(len(h.heap) - 1)
+}
+
+func (h *varHeap) pop() int {
+	v := h.heap[0]
+	n := len(h.heap) - 1
+	h.swap(0, n)
+	h.heap = h.heap[:n]
+	h.index[v] = -1
+	h.down(0)
+	return v
+}
+
+func (h *varHeap) up(i int) {
+	for i > 0 {
+		p := (i - 1) / 2
+		if !h.less(i, p) {
+			break
+		}
+		h.swap(i, p)
+		i = p
+	}
+}
+
+func (h *varHeap) down(i int) {
+	for {
+		l := 2*i + 1
+		if l >= len(h.heap) {
+			break
+		}
+		m := l
+		if r := l + 1; r < len(h.heap) && h.less(r, l) {
+			m = r
+		}
+		if !h.less(m, i) {
+			break
+		}
+		h.swap(i, m)
+		i = m
+	}
+}
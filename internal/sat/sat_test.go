@@ -0,0 +1,96 @@
+package sat
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func bruteForce(nv int, cls [][]int) bool {
+	for a := 0; a < 1<<uint(nv); a++ {
+		ok := true
+		for _, c := range cls {
+			sat := false
+			for _, l := range c {
+				if v := a&(1<<uint(abs(l)-1)) != 0; v == (l > 0) {
+					sat = true
+					break
+				}
+			}
+			if !sat {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSolver_random(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for n := 0; n < 500; n++ {
+		nv := 3 + rnd.Intn(10)
+		nc := rnd.Intn(nv * 6)
+		s := New()
+		for i := 0; i < nv; i++ {
+			s.NewVar()
+		}
+		var cls [][]int
+		for i := 0; i < nc; i++ {
+			c := make([]int, 1+rnd.Intn(3))
+			for j := range c {
+				c[j] = 1 + rnd.Intn(nv)
+				if rnd.Intn(2) == 0 {
+					c[j] = -c[j]
+				}
+			}
+			cls = append(cls, c)
+			s.AddClause(c...)
+		}
+		exp := bruteForce(nv, cls)
+		if got := s.Solve(); got != exp {
+			t.Fatalf("problem %d: Solve() = %v, expected %v", n, got, exp)
+		}
+		if !exp {
+			continue
+		}
+		// check solution
+		for _, c := range cls {
+			sat := false
+			for _, l := range c {
+				if s.Value(abs(l)) == (l > 0) {
+					sat = true
+				}
+			}
+			if !sat {
+				t.Fatalf("problem %d: clause %v not satisfied", n, c)
+			}
+		}
+	}
+}
+
+func TestSolver_pigeonhole(t *testing.T) {
+	// n+1 pigeons in n holes
+	const n = 6
+	s := New()
+	p := make([][]int, n+1)
+	for i := range p {
+		p[i] = make([]int, n)
+		for j := range p[i] {
+			p[i][j] = s.NewVar()
+		}
+		s.AddClause(p[i]...)
+	}
+	for j := 0; j < n; j++ {
+		for i := 0; i <= n; i++ {
+			for k := i + 1; k <= n; k++ {
+				s.AddClause(-p[i][j], -p[k][j])
+			}
+		}
+	}
+	if s.Solve() {
+		t.Fatal("pigeonhole problem is satisfiable")
+	}
+}
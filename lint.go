@@ -0,0 +1,99 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Lint returns warnings about suspicious wiring in the given part and in all
+// the chips it is built from. In order to keep chip interfaces simple, Chip
+// silently accepts:
+//
+//   - chip inputs that are not used by any part,
+//   - chip outputs that are not connected to any part output,
+//   - part inputs that are not connected (they are tied to False),
+//   - part outputs tied directly to an input of the same part (through
+//     PartSpec.Pinout),
+//   - parts whose outputs are all unused.
+//
+// These are however almost always typos in pin names.
+//
+// Warnings are returned as *WiringError values, chip by chip, starting with
+// the top-level part. Warnings about chip inputs and outputs have their Part
+// field set to -1. Lint returns nil if the part is not a chip built with Chip.
+//
+func Lint(part NewPartFn) []*WiringError {
+	var ws []*WiringError
+	seen := make(map[*chip]bool)
+	var walk func(p *PartSpec)
+	walk = func(p *PartSpec) {
+		c := p.chip
+		if c == nil || seen[c] {
+			return
+		}
+		seen[c] = true
+		ws = append(ws, c.warnings...)
+		for _, sp := range c.parts {
+			walk(sp)
+		}
+	}
+	walk(part("").PartSpec)
+	return ws
+}
+
+// lintIO returns warnings for unused chip inputs and unconnected chip outputs.
+// It must be called before pruning wr.
+//
+func lintIO(name string, ins, outs []string, wr wiring) []*WiringError {
+	var ws []*WiringError
+	for _, in := range ins {
+		if n := wr[pin{-1, in}]; len(n.outs) == 0 {
+			ws = append(ws, &WiringError{Chip: name, Part: -1, Err: errors.New("input " + in + " not used")})
+		}
+	}
+	for _, out := range outs {
+		if n := wr[pin{-1, out}]; n.src == nil && len(n.outs) == 0 {
+			ws = append(ws, &WiringError{Chip: name, Part: -1, Err: errors.New("output " + out + " not connected")})
+		}
+	}
+	return ws
+}
+
+// lintParts returns warnings for unconnected inputs and unused outputs of the
+// given parts. It must be called after pruning wr.
+//
+func lintParts(name string, parts []Part, wr wiring) []*WiringError {
+	var ws []*WiringError
+	for pnum := range parts {
+		p := &parts[pnum]
+		warn := func(msg string) {
+			ws = append(ws, &WiringError{Chip: name, Part: pnum, PartName: p.Name, Conn: p.conn, Err: errors.New(msg)})
+		}
+		ins := make(map[string]string, len(p.Inputs))
+		for _, k := range p.Inputs {
+			subK := p.Pinout[k]
+			if subK == "" {
+				continue
+			}
+			ins[subK] = k
+			if wr[pin{pnum, k}] == nil {
+				warn("input " + k + " not connected, tied to false")
+			}
+		}
+		used := false
+		for _, k := range p.Outputs {
+			if in, ok := ins[p.Pinout[k]]; ok {
+				warn("output " + k + " tied directly to input " + in)
+			}
+			if n := wr[pin{pnum, k}]; n != nil && len(n.outs) > 0 {
+				used = true
+			}
+		}
+		if !used && len(p.Outputs) > 0 {
+			warn("all outputs unused")
+		}
+	}
+	return ws
+}
@@ -0,0 +1,59 @@
+package hwsim_test
+
+import (
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+func TestLint(t *testing.T) {
+	tied := (&hwsim.PartSpec{
+		Name:    "WIRE",
+		Inputs:  []string{"in"},
+		Outputs: []string{"out"},
+		Pinout:  map[string]string{"in": "w", "out": "w"},
+		Mount:   func(s *hwsim.Socket) hwsim.Updater { return hwsim.UpdaterFn(func(bool) {}) },
+	}).NewPart
+	sub, err := hwsim.Chip("SUB", "a, b, unused", "out, nc",
+		tl.nand("a=a, b=b, out=out"),
+		tl.not("in=a"),
+		tl.not("in=b"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top, err := hwsim.Chip("TOP", "a", "out",
+		sub("a=a, out=out"),
+		tied("in=a"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []struct {
+		chip string
+		part int
+		msg  string
+	}{
+		{"TOP", 0, "input b not connected, tied to false"},
+		{"TOP", 1, "output out tied directly to input in"},
+		{"TOP", 1, "all outputs unused"},
+		{"SUB", -1, "input unused not used"},
+		{"SUB", -1, "output nc not connected"},
+		{"SUB", 1, "all outputs unused"},
+		{"SUB", 2, "all outputs unused"},
+	}
+	ws := hwsim.Lint(top)
+	if len(ws) != len(exp) {
+		t.Fatalf("got %d warnings, expected %d: %v", len(ws), len(exp), ws)
+	}
+	for i, w := range ws {
+		if e := exp[i]; w.Chip != e.chip || w.Part != e.part || w.Err.Error() != e.msg {
+			t.Errorf("got warning %q, expected %q in chip %s, part %d", w, e.msg, e.chip, e.part)
+		}
+	}
+
+	if ws = hwsim.Lint(tl.nand); ws != nil {
+		t.Errorf("got %v for NAND", ws)
+	}
+}
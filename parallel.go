@@ -0,0 +1,157 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// A worker is the share of a circuit's components evaluated by a single
+// goroutine during each half clock cycle.
+//
+type worker struct {
+	prog []step
+	ups  []PostUpdater
+	size int
+}
+
+// SetWorkers enables parallel simulation with n workers. If n <= 0,
+// runtime.GOMAXPROCS(0) workers are used. SetWorkers(1) restores serial
+// simulation.
+//
+// The compiled program of the circuit is split into independent partitions:
+// sets of components that do not share any wire other than the outputs of
+// PostUpdaters (like DFFs) and constant wires. During each half clock cycle,
+// partitions are then evaluated concurrently, followed by the PostUpdate
+// method of PostUpdaters. As a consequence, the functions passed to Output
+// and OutputN may be called concurrently.
+//
+// SetWorkers has no effect on circuits that could not be compiled because of
+// wiring loops.
+//
+func (c *Circuit) SetWorkers(n int) {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	c.workers = nil
+	if n == 1 || c.prog == nil {
+		return
+	}
+
+	parts := c.partitions()
+	if len(parts) < n {
+		n = len(parts)
+	}
+	if n <= 1 {
+		return
+	}
+
+	// assign the largest partitions first to the least loaded worker.
+	sort.SliceStable(parts, func(i, j int) bool { return len(parts[i]) > len(parts[j]) })
+	ws := make([]worker, n)
+	for _, p := range parts {
+		w := &ws[0]
+		for i := range ws {
+			if ws[i].size < w.size {
+				w = &ws[i]
+			}
+		}
+		w.size += len(p)
+		for _, i := range p {
+			w.prog = append(w.prog, c.prog[i])
+		}
+	}
+	for i, u := range c.ups {
+		w := &ws[i%n]
+		w.ups = append(w.ups, u)
+	}
+	c.workers = ws
+}
+
+// Workers returns the number of workers used by the simulation.
+//
+func (c *Circuit) Workers() int {
+	if c.workers == nil {
+		return 1
+	}
+	return len(c.workers)
+}
+
+// partitions splits the compiled program in independent partitions. Each
+// partition is a list of indices in c.prog, in topological order.
+//
+func (c *Circuit) partitions() [][]int {
+	parent := make([]int, len(c.prog))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	driver := make(map[*Wire]int)
+	for i := range c.prog {
+		for _, w := range c.prog[i].out {
+			if w != nil {
+				driver[w] = i
+			}
+		}
+	}
+	for i := range c.prog {
+		for _, w := range c.prog[i].in {
+			if d, ok := driver[w]; ok {
+				parent[find(d)] = find(i)
+			}
+		}
+	}
+
+	idx := make(map[int]int)
+	var parts [][]int
+	for i := range c.prog {
+		r := find(i)
+		n, ok := idx[r]
+		if !ok {
+			n = len(parts)
+			idx[r] = n
+			parts = append(parts, nil)
+		}
+		parts[n] = append(parts[n], i)
+	}
+	return parts
+}
+
+// runWorkers calls f for each worker concurrently and waits for all of them
+// to complete.
+//
+func (c *Circuit) runWorkers(f func(w *worker)) {
+	var wg sync.WaitGroup
+	wg.Add(len(c.workers) - 1)
+	for i := 1; i < len(c.workers); i++ {
+		go func(w *worker) {
+			f(w)
+			wg.Done()
+		}(&c.workers[i])
+	}
+	f(&c.workers[0])
+	wg.Wait()
+}
+
+func (c *Circuit) updateParallel(clk bool) {
+	c.runWorkers(func(w *worker) {
+		for i := range w.prog {
+			w.prog[i].run(clk)
+		}
+	})
+	c.runWorkers(func(w *worker) {
+		for _, u := range w.ups {
+			u.PostUpdate(clk)
+		}
+	})
+}
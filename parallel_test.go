@@ -0,0 +1,61 @@
+package hwsim_test
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+// accumulators returns a circuit with n independent 4 bits accumulators:
+// out[i] = (out[i] + in[i]) & 15 on every clock cycle.
+//
+func accumulators(t *testing.T, in, out []uint64) *hwsim.Circuit {
+	var parts []hwsim.Part
+	for i := range in {
+		i := i
+		n := strconv.Itoa(i)
+		parts = append(parts,
+			hwsim.InputN(4, func() uint64 { return in[i] })("out[0..3]=in"+n+"[0..3]"),
+			tl.cla4("a[0..3]=acc"+n+"[0..3], b[0..3]=in"+n+"[0..3], out[0..3]=sum"+n+"[0..3]"),
+			hwsim.OutputN(4, func(v uint64) { out[i] = v })("in[0..3]=acc"+n+"[0..3]"),
+		)
+		for b := 0; b < 4; b++ {
+			bit := "[" + strconv.Itoa(b) + "]"
+			parts = append(parts, tl.dff("in=sum"+n+bit+", out=acc"+n+bit))
+		}
+	}
+	c, err := hwsim.NewCircuit(parts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCircuit_SetWorkers(t *testing.T) {
+	const n = 4
+	var in, out, exp [n]uint64
+	c := accumulators(t, in[:], out[:])
+	c.SetWorkers(n)
+	if w := c.Workers(); w != n {
+		t.Fatalf("expected %d workers, got %d", n, w)
+	}
+	for cycle := 0; cycle < 100; cycle++ {
+		for i := range in {
+			in[i] = uint64(rand.Intn(16))
+		}
+		c.TickTock()
+		for i := range in {
+			exp[i] = (exp[i] + in[i]) & 15
+			if out[i] != exp[i] {
+				t.Fatalf("cycle %d: acc%d = %d, expected %d", cycle, i, out[i], exp[i])
+			}
+		}
+	}
+
+	c.SetWorkers(1)
+	if w := c.Workers(); w != 1 {
+		t.Fatalf("expected 1 worker, got %d", w)
+	}
+}
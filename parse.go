@@ -78,48 +78,129 @@ func ParseIOSpec(names string) ([]string, error) {
 // into a []Connection{{PP: "partPinX", CP: []string{"chipPinX"}}, ...}.
 //
 //	Wire       = Assignment { [ space ] "," [ space ] Assignment } .
-//	Assignment = Pin "=" Pin .
+//	Assignment = Pin "=" ( Pin | Literal | Concat ) .
 //	Pin        = identifier [ "[" Index | Range "]" ] .
+//	Concat     = "{" Pin { "," Pin } "}" .
 //	Index      = integer .
 //	Range      = integer ".." integer .
+//	Literal    = integer | "0x" hex_digit { hex_digit } | "0b" bin_digit { bin_digit } .
 //	identifier = letter { letter | digit } .
 //	integer    = { digit } .
 //	letter     = "A" ... "Z" | "a" ... "z" | "_" .
 //	digit      = "0" ... "9" .
 //
+// Literal values are spread across the bits of the part pins, LSB first, and
+// connected to the constant inputs true and false:
+//
+//	"a[0..3]=0xA" is equivalent to "a[0]=false, a[1]=true, a[2]=false, a[3]=true"
+//
+// A pin range on the right-hand side can be mapped onto a whole part bus of
+// the same size, like "in=x[8..15]" where in is an 8 bits bus. Concatenations
+// list pins and pin ranges from the most significant bits to the least
+// significant ones, as in Verilog:
+//
+//	"in={hi[0..7], lo[0..7]}" is equivalent to "in[0..7]=lo[0..7], in[8..15]=hi[0..7]"
+//
+// A literal wider than the pin range is an error. A literal assigned to a pin
+// name without index or range, like "sel=1" or "a=0x3F" where a is a bus, is
+// returned as a single connection with Const set and is resolved by Chip,
+// depending on the size of the pin or bus.
+//
+// Syntax errors are returned as a *WiringError with the column of the
+// offending token.
+//
 func ParseConnections(c string) (conns []Connection, err error) {
+	conns, _, err = parseConnections(c)
+	return conns, err
+}
+
+// connPos holds the columns of the part pin and chip pin of a connection in
+// the original connection string.
+//
+type connPos struct {
+	pp, cp int
+}
+
+// parseConnections works like ParseConnections and also returns the position
+// of every connection in c. Errors are of type *WiringError.
+//
+func parseConnections(c string) (conns []Connection, pos []connPos, err error) {
 	p := &hdl.Parser{Input: c}
 
 	for {
 		e, err := p.Next(true, true)
 		if err != nil {
-			return nil, err
+			if pe, ok := err.(*hdl.Error); ok {
+				return nil, nil, &WiringError{Part: -1, Conn: c, Col: pe.Pos + 1, Err: errors.New(pe.Msg)}
+			}
+			return nil, nil, err
 		}
 		if e == nil {
-			return conns, nil
+			return conns, pos, nil
 		}
 		// p.Next(true, ???) should only return PinAssignments. Failure to do so would be a bug, so just let it panic.
 		m := e.(*hdl.PinAssignment)
+		cp := connPos{m.LHS.Pos() + 1, m.RHS.Pos() + 1}
 		ks := expandRange(m.LHS)
+		if c, ok := m.RHS.(*hdl.Constant); ok {
+			if _, ok = m.LHS.(*hdl.Pin); ok {
+				// single pin or whole bus, resolved by Chip
+				conns = append(conns, Connection{PP: ks[0], Const: true, Value: c.Value})
+				pos = append(pos, cp)
+				continue
+			}
+			cs, err := spreadConstant(c.Value, len(ks))
+			if err != nil {
+				return nil, nil, &WiringError{Part: -1, Conn: p.Input, Col: cp.cp, Err: err}
+			}
+			for i := range ks {
+				conns = append(conns, Connection{PP: ks[i], CP: cs[i : i+1]})
+				pos = append(pos, cp)
+			}
+			continue
+		}
 		vs := expandRange(m.RHS)
+		// anything but a plain pin name lists the individual bits of a bus
+		_, whole := m.RHS.(*hdl.Pin)
 		switch {
 		case len(ks) == len(vs):
 			// many to many
 			for i := range ks {
-				conns = append(conns, Connection{ks[i], []string{vs[i]}})
+				conns = append(conns, Connection{PP: ks[i], CP: []string{vs[i]}, Bits: !whole})
+				pos = append(pos, cp)
 			}
 		case len(ks) == 1:
 			// one to nany
-			conns = append(conns, Connection{ks[0], vs})
+			conns = append(conns, Connection{PP: ks[0], CP: vs, Bits: !whole})
+			pos = append(pos, cp)
 		case len(vs) == 1:
 			// many to one
 			for _, k := range ks {
-				conns = append(conns, Connection{k, vs})
+				conns = append(conns, Connection{PP: k, CP: vs, Bits: !whole})
+				pos = append(pos, cp)
 			}
 		default:
-			return nil, errors.New("pin count mismatch in pin mapping: " + m.String())
+			return nil, nil, &WiringError{Part: -1, Conn: c, Col: cp.pp, Err: errors.New("pin count mismatch in pin mapping: " + m.String())}
+		}
+	}
+}
+
+// spreadConstant returns the constant pin names True or False for each bit
+// of v, LSB first. It returns an error if v does not fit in the given number
+// of bits.
+//
+func spreadConstant(v uint64, bits int) ([]string, error) {
+	if bits < 64 && v>>uint(bits) != 0 {
+		return nil, errors.Errorf("constant %d wider than %d bits", v, bits)
+	}
+	cs := make([]string, bits)
+	for i := range cs {
+		cs[i] = False
+		if v&(1<<uint(i)) != 0 {
+			cs[i] = True
 		}
 	}
+	return cs, nil
 }
 
 func expandRange(v hdl.PinExpr) []string {
@@ -134,6 +215,13 @@ func expandRange(v hdl.PinExpr) []string {
 		return []string{pinName(p.Name, p.Index)}
 	case *hdl.Pin:
 		return []string{p.Name}
+	case *hdl.Concat:
+		// the first pin is the MSB
+		var l []string
+		for i := len(p.Pins) - 1; i >= 0; i-- {
+			l = append(l, expandRange(p.Pins[i])...)
+		}
+		return l
 	default:
 		panic("BUG: unexpected PinExpr type")
 	}
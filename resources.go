@@ -0,0 +1,123 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Resources is the resource usage report of a part, as returned by
+// ResourceUsage.
+//
+type Resources struct {
+	Name  string      // part name
+	Gates int         // size of the part in NAND-equivalent gates
+	Parts []PartUsage // sub-parts of a chip grouped by name, nil for other parts
+}
+
+// PartUsage is the resource usage of all the sub-parts with the same name in
+// a chip.
+//
+type PartUsage struct {
+	*Resources     // resource usage of the first sub-part with that name
+	Count      int // number of sub-parts
+	Gates      int // total size of the sub-parts in NAND-equivalent gates
+}
+
+// ResourceUsage returns the resource usage of the given part: the number of
+// sub-parts of each type in each chip of the hierarchy and their size in
+// NAND-equivalent gates (see PartSpec.Gates).
+//
+func ResourceUsage(part NewPartFn) *Resources {
+	return resourceUsage(part("").PartSpec, make(map[*PartSpec]*Resources))
+}
+
+// ResourceUsage returns the resource usage of the circuit. See
+// ResourceUsage.
+//
+func (c *Circuit) ResourceUsage() *Resources {
+	return resourceUsage(c.spec, make(map[*PartSpec]*Resources))
+}
+
+func resourceUsage(p *PartSpec, m map[*PartSpec]*Resources) *Resources {
+	if r := m[p]; r != nil {
+		return r
+	}
+	r := &Resources{Name: p.Name}
+	m[p] = r
+	switch {
+	case p.chip != nil:
+		idx := make(map[string]int)
+		r.Parts = []PartUsage{}
+		for _, sp := range p.chip.parts {
+			sr := resourceUsage(sp, m)
+			i, ok := idx[sp.Name]
+			if !ok {
+				i = len(r.Parts)
+				idx[sp.Name] = i
+				r.Parts = append(r.Parts, PartUsage{Resources: sr})
+			}
+			r.Parts[i].Count++
+			r.Parts[i].Gates += sr.Gates
+			r.Gates += sr.Gates
+		}
+	case p.Gates == 0 && p.Impl != nil:
+		r.Gates = resourceUsage(p.Impl("").PartSpec, m).Gates
+	default:
+		r.Gates = p.Gates
+	}
+	return r
+}
+
+// String returns a report of the resource usage of r and its sub-chips, one
+// chip per line, indented by hierarchy level, like:
+//
+//	Adder: 2 FullAdder → 30 NAND-equivalents
+//	  FullAdder: 2 XOR, 2 AND, 1 OR → 15 NAND-equivalents
+//
+func (r *Resources) String() string {
+	var b strings.Builder
+	seen := make(map[*Resources]bool)
+	var walk func(r *Resources, level int)
+	walk = func(r *Resources, level int) {
+		seen[r] = true
+		b.WriteString(strings.Repeat("  ", level))
+		b.WriteString(r.Name)
+		b.WriteString(": ")
+		for i, pu := range r.Parts {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(strconv.Itoa(pu.Count))
+			b.WriteRune(' ')
+			b.WriteString(pu.Name)
+		}
+		if len(r.Parts) > 0 {
+			b.WriteString(" → ")
+		}
+		b.WriteString(thousands(r.Gates))
+		b.WriteString(" NAND-equivalents\n")
+		for _, pu := range r.Parts {
+			if pu.Parts != nil && !seen[pu.Resources] {
+				walk(pu.Resources, level+1)
+			}
+		}
+	}
+	walk(r, 0)
+	return b.String()
+}
+
+// thousands formats n with a comma as thousands separator.
+//
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	if n < 0 {
+		return "-" + thousands(-n)
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
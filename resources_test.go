@@ -0,0 +1,51 @@
+package hwsim_test
+
+import (
+	"testing"
+
+	"github.com/db47h/hwsim"
+	hl "github.com/db47h/hwsim/hwlib"
+)
+
+func TestResourceUsage(t *testing.T) {
+	fa, err := hwsim.Chip("FullAdder", "a, b, c", "s, co",
+		hl.Xor("a=a, b=b, out=p"),
+		hl.Xor("a=p, b=c, out=s"),
+		hl.And("a=a, b=b, out=g"),
+		hl.And("a=p, b=c, out=pc"),
+		hl.Or("a=g, b=pc, out=co"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add2, err := hwsim.Chip("Adder2", "a[2], b[2]", "out[2], c",
+		fa("a=a[0], b=b[0], s=out[0], co=c0"),
+		fa("a=a[1], b=b[1], c=c0, s=out[1], co=c"),
+		hl.MuxN(16)(""),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := hwsim.ResourceUsage(add2)
+	exp := "Adder2: 2 FullAdder, 1 Mux16 → 94 NAND-equivalents\n" +
+		"  FullAdder: 2 XOR, 2 AND, 1 OR → 15 NAND-equivalents\n"
+	if s := r.String(); s != exp {
+		t.Fatalf("expected report:\n%s\ngot:\n%s", exp, s)
+	}
+	if len(r.Parts) != 2 || r.Parts[0].Count != 2 || r.Parts[0].Gates != 30 || r.Parts[0].Resources.Gates != 15 || r.Parts[1].Gates != 64 {
+		t.Fatalf("unexpected resource usage %+v", r.Parts)
+	}
+
+	c, err := hwsim.NewCircuit(
+		hwsim.InputN(2, func() uint64 { return 3 })("out=a"),
+		add2("a=a, b=a, out=s"),
+		hwsim.OutputN(2, func(v uint64) {})("in=s"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r = c.ResourceUsage(); r.Gates != 94 || len(r.Parts) != 3 || r.Parts[1].Name != "Adder2" || r.Parts[1].Count != 1 {
+		t.Fatalf("unexpected circuit resource usage:\n%v", r)
+	}
+}
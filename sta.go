@@ -0,0 +1,168 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Path is a combinational path through a circuit, as returned by
+// CriticalPath.
+//
+type Path struct {
+	Delay int       // total propagation delay
+	Pins  []PathPin // pins along the path
+}
+
+// A PathPin is a pin along a Path.
+//
+type PathPin struct {
+	Name string // hierarchical pin name, or wire name for the path's end points
+	Time int    // arrival time of signals on the pin
+}
+
+// String returns a one pin per line representation of the path.
+//
+func (p *Path) String() string {
+	var b strings.Builder
+	for _, pp := range p.Pins {
+		b.WriteString(strconv.Itoa(pp.Time))
+		b.WriteRune('\t')
+		b.WriteString(pp.Name)
+		b.WriteRune('\n')
+	}
+	return b.String()
+}
+
+// CriticalPath returns the longest combinational path between any input of
+// the given part, or output of a clocked component within it, and any output
+// of the part or input of a clocked component.
+//
+// The delay function returns the propagation delay of a given part type. If
+// nil, PartSpec.Delay is used, with a default of 1 time unit. Note that
+// parts built with Chip are not components: the delay of a chip is the delay
+// of the paths through its parts.
+//
+func CriticalPath(part NewPartFn, delay func(*PartSpec) int) (*Path, error) {
+	spec := part("").PartSpec
+	var parts []Part
+	var conns []string
+	sinks := make(map[*PartSpec]bool)
+	for _, in := range spec.Inputs {
+		parts = append(parts, Input(func() bool { return false })("out="+in))
+		conns = append(conns, in+"="+in)
+	}
+	for _, out := range spec.Outputs {
+		p := Output(func(bool) {})("in=" + out)
+		sinks[p.PartSpec] = true
+		parts = append(parts, p)
+		conns = append(conns, out+"="+out)
+	}
+	parts = append(parts, part(strings.Join(conns, ",")))
+	c, err := NewCircuit(parts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.criticalPath(delay, sinks)
+}
+
+// CriticalPath returns the longest combinational path between the output of
+// any clocked component (PostUpdater) or input in the circuit and the input
+// of any clocked component. See CriticalPath.
+//
+func (c *Circuit) CriticalPath(delay func(*PartSpec) int) (*Path, error) {
+	return c.criticalPath(delay, nil)
+}
+
+func (c *Circuit) criticalPath(df func(*PartSpec) int, sinks map[*PartSpec]bool) (*Path, error) {
+	if df == nil {
+		df = delay
+	}
+	isSource := func(cp *Component) bool {
+		_, ok := cp.Updater.(PostUpdater)
+		return ok || len(cp.Inputs) == 0
+	}
+	comps, err := sortComponents(c.comps, isSource)
+	if err != nil {
+		return nil, err
+	}
+
+	// arrival times and the component pins through which they were reached
+	type via struct {
+		cp      *Component
+		in, out int
+	}
+	arr := make(map[*Wire]int)
+	from := make(map[*Wire]via)
+	for _, cp := range c.comps {
+		if isSource(cp) {
+			for _, w := range cp.Outputs {
+				if w != nil {
+					arr[w] = 0
+				}
+			}
+		}
+	}
+	for _, cp := range comps {
+		t, in := -1, -1
+		for i, w := range cp.Inputs {
+			if a, ok := arr[w]; ok && a > t {
+				t, in = a, i
+			}
+		}
+		if in < 0 {
+			continue
+		}
+		t += df(cp.Spec)
+		for i, w := range cp.Outputs {
+			if w != nil {
+				arr[w] = t
+				from[w] = via{cp, in, i}
+			}
+		}
+	}
+
+	var (
+		end   *Wire
+		endCp *Component
+		endIn int
+	)
+	for _, cp := range c.comps {
+		if _, ok := cp.Updater.(PostUpdater); !ok {
+			continue
+		}
+		for i, w := range cp.Inputs {
+			if a, ok := arr[w]; ok && (end == nil || a > arr[end]) {
+				end, endCp, endIn = w, cp, i
+			}
+		}
+	}
+	if end == nil {
+		return nil, errors.New("no combinational path")
+	}
+
+	p := &Path{Delay: arr[end]}
+	if sinks[endCp.Spec] {
+		p.Pins = append(p.Pins, PathPin{c.WireName(end), arr[end]})
+	} else {
+		p.Pins = append(p.Pins, PathPin{endCp.Name + "." + endCp.Spec.Inputs[endIn], arr[end]})
+	}
+	for w := end; ; {
+		v, ok := from[w]
+		if !ok {
+			p.Pins = append(p.Pins, PathPin{c.WireName(w), 0})
+			break
+		}
+		p.Pins = append(p.Pins, PathPin{v.cp.Name + "." + v.cp.Spec.Outputs[v.out], arr[w]})
+		w = v.cp.Inputs[v.in]
+		p.Pins = append(p.Pins, PathPin{v.cp.Name + "." + v.cp.Spec.Inputs[v.in], arr[w]})
+	}
+	for i, j := 0, len(p.Pins)-1; i < j; i, j = i+1, j-1 {
+		p.Pins[i], p.Pins[j] = p.Pins[j], p.Pins[i]
+	}
+	return p, nil
+}
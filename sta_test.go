@@ -0,0 +1,93 @@
+package hwsim_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+func TestCriticalPath(t *testing.T) {
+	p, err := hwsim.CriticalPath(tl.xor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Delay != 3 || p.Pins[0].Name != "a" || p.Pins[len(p.Pins)-1].Name != "out" {
+		t.Fatalf("unexpected critical path for xor, delay %d:\n%v", p.Delay, p)
+	}
+
+	p, err = hwsim.CriticalPath(tl.xor, func(*hwsim.PartSpec) int { return 2 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Delay != 6 {
+		t.Fatalf("expected delay 6 for xor with 2 units NANDs, got %d", p.Delay)
+	}
+
+	// ripple carry vs. carry lookahead
+	fa, err := hwsim.Chip("FullAdder", "a, b, c", "s, co",
+		tl.xor("a=a, b=b, out=p"),
+		tl.xor("a=p, b=c, out=s"),
+		tl.and("a=a, b=b, out=g"),
+		tl.and("a=p, b=c, out=pc"),
+		tl.or("a=g, b=pc, out=co"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parts []hwsim.Part
+	for i := 0; i < 16; i++ {
+		n := strconv.Itoa(i)
+		ci := "c[" + n + "]"
+		if i == 0 {
+			ci = "false"
+		}
+		parts = append(parts, fa("a=a["+n+"], b=b["+n+"], c="+ci+", s=out["+n+"], co=c["+strconv.Itoa(i+1)+"]"))
+	}
+	ripple, err := hwsim.Chip("Ripple16", "a[16], b[16]", "out[16], c", append(parts, tl.or("a=c[16], b=false, out=c"))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cla, err := hwsim.Chip("CLA16", "a[16], b[16]", "out[16], c",
+		tl.lcu("p[0..3]=p[0..3], g[0..3]=g[0..3], g=c, c1=c1, c2=c2, c3=c3"),
+		tl.cla4("a[0..3]=a[0..3],   b[0..3]=b[0..3],          out[0..3]=out[0..3],   p=p[0], g=g[0]"),
+		tl.cla4("a[0..3]=a[4..7],   b[0..3]=b[4..7],   c0=c1, out[0..3]=out[4..7],   p=p[1], g=g[1]"),
+		tl.cla4("a[0..3]=a[8..11],  b[0..3]=b[8..11],  c0=c2, out[0..3]=out[8..11],  p=p[2], g=g[2]"),
+		tl.cla4("a[0..3]=a[12..15], b[0..3]=b[12..15], c0=c3, out[0..3]=out[12..15], p=p[3], g=g[3]"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp, err := hwsim.CriticalPath(ripple, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := hwsim.CriticalPath(cla, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Delay >= rp.Delay {
+		t.Fatalf("carry lookahead adder delay %d >= ripple carry adder delay %d", cp.Delay, rp.Delay)
+	}
+	if !strings.HasPrefix(rp.Pins[1].Name, "Ripple16.FullAdder#0.") {
+		t.Fatalf("ripple carry critical path does not start at the first full adder:\n%v", rp)
+	}
+
+	// register to register
+	c, err := hwsim.NewCircuit(
+		tl.dff("in=x, out=q"),
+		tl.not("in=q, out=y"),
+		tl.xor("a=y, b=q, out=x"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = c.CriticalPath(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Delay != 4 || p.Pins[0].Name != "q" || p.Pins[len(p.Pins)-1].Name != "dff.in" {
+		t.Fatalf("unexpected critical path, delay %d:\n%v", p.Delay, p)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2018 Denis Bernard <db047h@gmail.com>
+// Licensed under the MIT license. See license text in the LICENSE file.
+
+package hwsim
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Timing is the timing report of a half clock cycle simulated in timed mode.
+// See Circuit.SetTimed.
+//
+type Timing struct {
+	Settle int          // time at which the last wire settled
+	Wires  []WireTiming // wires that changed during the half clock cycle
+}
+
+// WireTiming is the timing report of a single wire.
+//
+type WireTiming struct {
+	Name        string // canonical wire name
+	Transitions int    // number of value changes
+	Settle      int    // time of the last value change
+}
+
+// Glitch returns true if the wire changed more than once before settling.
+//
+func (w *WireTiming) Glitch() bool {
+	return w.Transitions > 1
+}
+
+// Glitches returns the wires that glitched.
+//
+func (t *Timing) Glitches() []WireTiming {
+	var gs []WireTiming
+	for _, w := range t.Wires {
+		if w.Glitch() {
+			gs = append(gs, w)
+		}
+	}
+	return gs
+}
+
+// delay returns the propagation delay of parts of type spec.
+//
+func delay(spec *PartSpec) int {
+	if spec.Delay <= 0 {
+		return 1
+	}
+	return spec.Delay
+}
+
+// SetTimed enables or disables timed simulation.
+//
+// In timed mode, changes in combinational logic are propagated as events in
+// time order during each half clock cycle: time 0 is the clock edge, where
+// clocked components (PostUpdaters) and parts without inputs like Input
+// update their outputs. Any other part updates its outputs PartSpec.Delay
+// time units after one of its inputs changed. Every intermediate change is
+// propagated, which makes glitches visible. Once all wires have settled,
+// clocked components sample their inputs as usual.
+//
+// The first half cycle simulated after enabling timed mode evaluates all
+// parts at time 0 in order to bring the circuit to a stable state.
+//
+// Timed simulation is much slower than the default mode and is meant for
+// teaching and timing analysis. The timing report of the last half cycle is
+// available with Circuit.Timing. If the circuit does not settle, because of
+// a wiring loop, the simulation panics.
+//
+func (c *Circuit) SetTimed(on bool) {
+	if !on {
+		c.timed = nil
+		return
+	}
+	c.timed = newTimedSim(c)
+}
+
+// Timing returns the timing report of the last half clock cycle simulated in
+// timed mode. It returns nil if timed mode is not enabled or if no half cycle
+// has been simulated since it was enabled.
+//
+func (c *Circuit) Timing() *Timing {
+	if c.timed == nil {
+		return nil
+	}
+	return c.timed.report
+}
+
+type event struct {
+	t, seq int
+	w      int
+	v      bool
+}
+
+type eventQueue []event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	return q[i].t < q[j].t || q[i].t == q[j].t && q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(event)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+type timedComp struct {
+	cp    *Component
+	outs  []int // wire indices of outputs
+	delay int
+	post  bool // PostUpdater
+}
+
+type timedSim struct {
+	c      *Circuit
+	widx   map[*Wire]int
+	comps  []timedComp
+	fanout [][]int // combinational components driven by each wire
+	limit  int     // upper bound of the settling time
+	init   bool
+	report *Timing
+
+	q       eventQueue
+	seq     int
+	proj    []bool // projected wire values
+	trans   []int
+	last    []int
+	mark    []int
+	stamp   int
+	pending []int
+	vs, old []bool
+}
+
+func newTimedSim(c *Circuit) *timedSim {
+	ts := &timedSim{
+		c:      c,
+		widx:   make(map[*Wire]int, len(c.wires)),
+		fanout: make([][]int, len(c.wires)),
+		proj:   make([]bool, len(c.wires)),
+		trans:  make([]int, len(c.wires)),
+		last:   make([]int, len(c.wires)),
+		init:   true,
+	}
+	for i, w := range c.wires {
+		ts.widx[w] = i
+	}
+	for _, cp := range c.comps {
+		tc := timedComp{cp: cp, delay: delay(cp.Spec)}
+		_, tc.post = cp.Updater.(PostUpdater)
+		for _, w := range cp.Outputs {
+			if w != nil {
+				tc.outs = append(tc.outs, ts.widx[w])
+			}
+		}
+		if len(tc.outs) == 0 {
+			continue
+		}
+		if len(cp.Outputs) > len(ts.vs) {
+			ts.vs = make([]bool, len(cp.Outputs))
+			ts.old = make([]bool, len(cp.Outputs))
+		}
+		n := len(ts.comps)
+		ts.comps = append(ts.comps, tc)
+		if tc.post {
+			continue
+		}
+		ts.limit += tc.delay
+		for _, w := range cp.Inputs {
+			if w != nil {
+				i := ts.widx[w]
+				ts.fanout[i] = append(ts.fanout[i], n)
+			}
+		}
+	}
+	ts.mark = make([]int, len(ts.comps))
+	return ts
+}
+
+// eval updates component n and returns the new values of its outputs without
+// changing the output wires.
+//
+func (ts *timedSim) eval(n int, clk bool) []bool {
+	tc := &ts.comps[n]
+	vs, old := ts.vs[:len(tc.outs)], ts.old[:len(tc.outs)]
+	for j, i := range tc.outs {
+		w := ts.c.wires[i]
+		old[j] = w.value
+		w.clk = !clk
+	}
+	tc.cp.Updater.Update(clk)
+	for j, i := range tc.outs {
+		w := ts.c.wires[i]
+		vs[j], w.value = w.value, old[j]
+		w.clk = clk
+	}
+	return vs
+}
+
+// evalAt evaluates component n and schedules the changes of its outputs at
+// time t.
+//
+func (ts *timedSim) evalAt(n int, clk bool, t int) {
+	for j, v := range ts.eval(n, clk) {
+		ts.schedule(t, ts.comps[n].outs[j], v)
+	}
+}
+
+func (ts *timedSim) schedule(t int, w int, v bool) {
+	if ts.proj[w] == v {
+		return
+	}
+	ts.proj[w] = v
+	heap.Push(&ts.q, event{t: t, seq: ts.seq, w: w, v: v})
+	ts.seq++
+}
+
+func (ts *timedSim) update(clk bool) {
+	c := ts.c
+	for i, w := range c.wires {
+		w.clk = clk
+		ts.proj[i] = w.value
+		ts.trans[i] = 0
+	}
+
+	// clock edge
+	for i := 0; i < cstCount; i++ {
+		w := c.wires[i]
+		old := w.value
+		w.clk = !clk
+		v := w.Recv(clk)
+		w.value = old
+		ts.schedule(0, i, v)
+	}
+	for n := range ts.comps {
+		tc := &ts.comps[n]
+		switch {
+		case tc.post || len(tc.cp.Inputs) == 0:
+			ts.evalAt(n, clk, 0)
+		case ts.init:
+			ts.evalAt(n, clk, tc.delay)
+		}
+	}
+	ts.init = false
+
+	for len(ts.q) > 0 {
+		t := ts.q[0].t
+		if t > ts.limit {
+			panic("timed simulation: circuit does not settle")
+		}
+		ts.stamp++
+		for len(ts.q) > 0 && ts.q[0].t == t {
+			e := heap.Pop(&ts.q).(event)
+			w := c.wires[e.w]
+			if w.value == e.v {
+				continue
+			}
+			w.value = e.v
+			ts.trans[e.w]++
+			ts.last[e.w] = t
+			for _, n := range ts.fanout[e.w] {
+				if ts.mark[n] != ts.stamp {
+					ts.mark[n] = ts.stamp
+					ts.pending = append(ts.pending, n)
+				}
+			}
+		}
+		sort.Ints(ts.pending)
+		for _, n := range ts.pending {
+			ts.evalAt(n, clk, t+ts.comps[n].delay)
+		}
+		ts.pending = ts.pending[:0]
+	}
+
+	r := new(Timing)
+	for i, w := range c.wires {
+		if ts.trans[i] == 0 {
+			continue
+		}
+		r.Wires = append(r.Wires, WireTiming{Name: c.WireName(w), Transitions: ts.trans[i], Settle: ts.last[i]})
+		if ts.last[i] > r.Settle {
+			r.Settle = ts.last[i]
+		}
+	}
+	ts.report = r
+}
@@ -0,0 +1,75 @@
+package hwsim_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/db47h/hwsim"
+)
+
+func TestCircuit_SetTimed(t *testing.T) {
+	var a, out bool
+	c, err := hwsim.NewCircuit(
+		hwsim.Input(func() bool { return a })("out=a"),
+		tl.nand("a=a, b=a, out=notA"),
+		tl.nand("a=a, b=notA, out=x"), // x = !(a && !a) glitches when a rises
+		hwsim.Output(func(v bool) { out = v })("in=x"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetTimed(true)
+	if c.Timing() != nil {
+		t.Fatal("timing report available before the first half cycle")
+	}
+
+	c.Tick()
+	if !out {
+		t.Fatal("x = false after tick")
+	}
+
+	a = true
+	c.Tock()
+	tm := c.Timing()
+	if !out || tm.Settle != 2 {
+		t.Fatalf("a rising: x = %v, settle time = %d, expected x = true, settle time = 2", out, tm.Settle)
+	}
+	gs := tm.Glitches()
+	if len(gs) != 1 || gs[0].Name != "x" || gs[0].Transitions != 2 || gs[0].Settle != 2 {
+		t.Fatalf("a rising: unexpected glitches %v", gs)
+	}
+
+	a = false
+	c.Tick()
+	tm = c.Timing()
+	if !out || tm.Settle != 1 || len(tm.Glitches()) != 0 {
+		t.Fatalf("a falling: x = %v, settle time = %d, glitches %v", out, tm.Settle, tm.Glitches())
+	}
+
+	c.SetTimed(false)
+	a = true
+	c.Tock()
+	if !out || c.Timing() != nil {
+		t.Fatal("bad output after leaving timed mode")
+	}
+}
+
+func TestCircuit_SetTimed_loop(t *testing.T) {
+	c, err := hwsim.NewCircuit(
+		tl.nand("a=true, b=x, out=y"),
+		tl.nand("a=true, b=y, out=z"),
+		tl.nand("a=true, b=z, out=x"),
+		hwsim.Output(func(v bool) {})("in=x"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetTimed(true)
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "does not settle") {
+			t.Fatalf("expected panic, got %v", r)
+		}
+	}()
+	c.TickTock()
+}
@@ -17,6 +17,15 @@ var (
 	cstPinNames = [...]string{"false", "true", "clk"}
 )
 
+func isCstPin(name string) bool {
+	for _, n := range cstPinNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
 const (
 	cstFalse = iota
 	cstTrue
@@ -27,9 +36,19 @@ const (
 // A Connection represents a connection between the pin PP of a part and
 // the pins CP in its host chip.
 //
+// If PP is a bus, CP is connected to each of its bits: "a=b" connects a[i] to
+// b[i]. If Bits is true, CP is a bus slice or concatenation instead, and lists
+// one chip pin per bit of PP, LSB first.
+//
+// If Const is true, PP is connected to the literal Value instead, and CP is
+// empty. The bits of Value are spread across PP, LSB first, if PP is a bus.
+//
 type Connection struct {
-	PP string
-	CP []string
+	PP    string
+	CP    []string
+	Bits  bool   // CP holds one pin per bit of PP
+	Const bool   // PP is connected to Value
+	Value uint64 // literal value
 }
 
 // A Wire connects pins together. A Wire may have only one source pin and multiple
@@ -102,22 +121,31 @@ func pinName(name string, bit int) string {
 	return name + "[" + strconv.Itoa(bit) + "]"
 }
 
+// Value returns the last value sent on the wire. Unlike Recv, it does not
+// trigger an update of the source component.
+//
+func (c *Wire) Value() bool {
+	return c.value
+}
+
 // A Socket maps a part's internal pin names to Wires in a circuit.
 // See PartSpec.Pinout.
 //
 type Socket struct {
-	m map[string]*Wire
-	c *Circuit
+	m    map[string]*Wire
+	c    *Circuit
+	path string // hierarchical name prefix for wires in this socket
 }
 
-func newSocket(c *Circuit) *Socket {
+func newSocket(c *Circuit, path string) *Socket {
 	return &Socket{
 		m: map[string]*Wire{
 			False: c.wires[cstFalse],
 			True:  c.wires[cstTrue],
 			Clk:   c.wires[cstClk],
 		},
-		c: c,
+		c:    c,
+		path: path,
 	}
 }
 
@@ -135,6 +163,7 @@ func (s *Socket) wireOrNew(name string) *Wire {
 	if !ok {
 		p = s.c.allocPin()
 		s.m[name] = p
+		s.c.nameWire(s.path+name, p)
 	}
 	return p
 }